@@ -0,0 +1,179 @@
+// Command simrun plays stages headlessly with a simple aim-and-shoot bot, or
+// plays back a recorded replay. It needs no display, so CI can run thousands
+// of stages with it.
+package main
+
+import (
+	"brackeysGameJam/replay"
+	"brackeysGameJam/resources"
+	"brackeysGameJam/sim"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	runs := flag.Int("runs", 100, "number of stages to play")
+	stage := flag.Int("stage", 0, "stage index to play, -1 for all stages in turn")
+	maxFrames := flag.Int("frames", 60*60, "step limit per stage")
+	width := flag.Float64("width", 1920, "arena width")
+	height := flag.Float64("height", 1080, "arena height")
+	seed := flag.Int64("seed", 1, "seed of the first run, run i uses seed+i")
+	replayFile := flag.String("replay", "", "play back this replay file and report how the run ended")
+	stagesDir := flag.String("stages", "", "directory of stage files that replace or add to the built-in ones")
+	difficultyName := flag.String("difficulty", sim.OneHit.Name, "difficulty to play the stages with")
+	weaponSlot := flag.Int("weapon", 1, "weapon slot the bot fights with")
+	modeName := flag.String("mode", string(sim.Campaign), "mode to play, endless reports the average score")
+	flag.Parse()
+
+	difficulty, ok := sim.DifficultyByName(*difficultyName)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown difficulty %q\n", *difficultyName)
+		os.Exit(1)
+	}
+
+	mode, ok := sim.ModeByName(*modeName)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown mode %q\n", *modeName)
+		os.Exit(1)
+	}
+
+	stages, err := resources.LoadStages(*stagesDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load stages: %v\n", err)
+		os.Exit(1)
+	}
+	if *stage < -1 || *stage >= len(stages) {
+		fmt.Fprintf(os.Stderr, "stage %d does not exist, want 0 to %d or -1 for all\n", *stage, len(stages)-1)
+		os.Exit(1)
+	}
+
+	if *replayFile != "" {
+		if err := playReplay(*replayFile, stages); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cleared, died, timedOut, score := 0, 0, 0, 0
+	for i := 0; i < *runs; i++ {
+		stageIdx := *stage
+		if stageIdx < 0 {
+			stageIdx = i % len(stages)
+		}
+		world := sim.NewWorld(float32(*width), float32(*height), *seed+int64(i), stages)
+		world.SetDifficulty(difficulty)
+		world.SetMode(mode)
+		world.StartStage(stageIdx)
+
+		result := "timeout"
+		for f := 0; f < *maxFrames; f++ {
+			in := botInput(world, f)
+			if f == 0 {
+				in.Weapon = *weaponSlot
+			}
+			events := world.Update(in, sim.FixedStep)
+			if events.StageCleared {
+				result = "cleared"
+				break
+			}
+			if events.PlayerDied {
+				result = "died"
+				break
+			}
+		}
+		score += world.Score()
+		switch result {
+		case "cleared":
+			cleared++
+		case "died":
+			died++
+		default:
+			timedOut++
+		}
+	}
+	if mode == sim.Endless {
+		fmt.Printf("runs: %d died: %d timeout: %d average score: %.1f\n", *runs, died, timedOut, float64(score)/float64(*runs))
+		return
+	}
+	fmt.Printf("runs: %d cleared: %d died: %d timeout: %d\n", *runs, cleared, died, timedOut)
+}
+
+// botInput stands still and fires at the nearest enemy every other frame.
+func botInput(world *sim.World, frame int) sim.Input {
+	player := world.Player().Shape().Centroid()
+	var target sim.Vector2
+	best := float32(-1)
+	for _, obj := range world.Objects() {
+		if !obj.IsEnemy() {
+			continue
+		}
+		center := obj.Shape().Centroid()
+		dx := center.X - player.X
+		dy := center.Y - player.Y
+		distance := dx*dx + dy*dy
+		if best < 0 || distance < best {
+			best = distance
+			target = center
+		}
+	}
+	return sim.Input{
+		Aim:  target,
+		Fire: best >= 0 && frame%2 == 0,
+	}
+}
+
+// playReplay runs a whole recorded run through the same stage flow as the
+// game and prints where it ended.
+func playReplay(path string, stages []sim.StageDef) error {
+	recording, err := replay.Load(path)
+	if err != nil {
+		return err
+	}
+	if recording.GameVersion != sim.Version {
+		fmt.Printf("warning: recorded with version %s, this is %s\n", recording.GameVersion, sim.Version)
+	}
+	if err := recording.CheckStages(stages); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	difficulty, err := recording.DifficultyRule()
+	if err != nil {
+		return err
+	}
+	mode, err := recording.GameMode()
+	if err != nil {
+		return err
+	}
+	world := sim.NewWorld(recording.Width, recording.Height, recording.Seed, stages)
+	world.SetDifficulty(difficulty)
+	world.SetMode(mode)
+	world.StartStage(0)
+	playback := recording.Playback()
+	for step := 0; ; step++ {
+		in, ok := playback.Next()
+		if !ok {
+			fmt.Printf("seed %d: replay ended in stage %d after %d steps\n", recording.Seed, world.StageIdx()+1, step)
+			return nil
+		}
+		events := world.Update(in, sim.FixedStep)
+		if events.StageRestarted {
+			continue
+		}
+		if events.StageCleared {
+			if world.IsFinalStage() {
+				fmt.Printf("seed %d: won after %d steps\n", recording.Seed, step+1)
+				return nil
+			}
+			world.StartStage(world.StageIdx() + 1)
+		}
+		if events.PlayerDied && mode == sim.Endless {
+			fmt.Printf("seed %d: died with score %d after %d steps\n", recording.Seed, world.Score(), step+1)
+			return nil
+		}
+		if events.PlayerDied {
+			fmt.Printf("seed %d: died in stage %d after %d steps\n", recording.Seed, world.StageIdx()+1, step+1)
+			return nil
+		}
+	}
+}
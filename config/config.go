@@ -0,0 +1,52 @@
+// Package config finds the files the game keeps in the user's config
+// directory and writes them so a crash never leaves one half written. It
+// needs no window.
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dir is the game's directory in the user's config directory.
+const dir = "TheColdKiller"
+
+// Path is where the file at elem, relative to the game's directory, is kept
+// in the user's config directory.
+func Path(elem ...string) (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(append([]string{configDir, dir}, elem...)...), nil
+}
+
+// WriteFile writes data to path, creating the directory if needed. It
+// writes a temporary file next to it first and renames it over the old one,
+// so a crash halfway leaves the old file intact.
+func WriteFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	ext := filepath.Ext(path)
+	tmp, err := os.CreateTemp(dir, "."+strings.TrimSuffix(filepath.Base(path), ext)+"-*"+ext)
+	if err != nil {
+		return err
+	}
+	// after the rename this fails harmlessly
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"brackeysGameJam/settings"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"log"
+)
+
+// Action is something the player does, read from whatever inputs are bound
+// to it. The actions are in the order of settings.Actions.
+type Action int
+
+const (
+	MoveX Action = iota
+	MoveY
+	AimX
+	AimY
+	Fire
+	Dash
+	Reload
+	Pause
+)
+
+// gamepad is the gamepad the game listens to.
+const gamepad = 0
+
+// triggerThreshold is how far, between 0 and 1, a trigger must be pulled to
+// count as a press.
+const triggerThreshold = 0.75
+
+// device is what an input is on.
+type device int
+
+const (
+	keyboard device = iota
+	mouse
+	padButton
+	padAxis
+)
+
+// keyNames are the keys an action can be bound to, by the name the
+// settings file uses. Escape is left out, it always pauses and goes back.
+var keyNames = map[string]int32{
+	"Space":        rl.KeySpace,
+	"Tab":          rl.KeyTab,
+	"Enter":        rl.KeyEnter,
+	"Backspace":    rl.KeyBackspace,
+	"Up":           rl.KeyUp,
+	"Down":         rl.KeyDown,
+	"Left":         rl.KeyLeft,
+	"Right":        rl.KeyRight,
+	"LeftShift":    rl.KeyLeftShift,
+	"RightShift":   rl.KeyRightShift,
+	"LeftControl":  rl.KeyLeftControl,
+	"RightControl": rl.KeyRightControl,
+	"LeftAlt":      rl.KeyLeftAlt,
+	"RightAlt":     rl.KeyRightAlt,
+}
+
+func init() {
+	for key := int32(rl.KeyA); key <= rl.KeyZ; key++ {
+		keyNames[string(rune(key))] = key
+	}
+}
+
+var mouseButtonNames = map[string]int32{
+	"MouseLeft":   int32(rl.MouseButtonLeft),
+	"MouseRight":  int32(rl.MouseButtonRight),
+	"MouseMiddle": int32(rl.MouseButtonMiddle),
+}
+
+// padButtonNames name the face buttons after an Xbox pad.
+var padButtonNames = map[string]int32{
+	"PadA":          rl.GamepadButtonRightFaceDown,
+	"PadB":          rl.GamepadButtonRightFaceRight,
+	"PadX":          rl.GamepadButtonRightFaceLeft,
+	"PadY":          rl.GamepadButtonRightFaceUp,
+	"PadUp":         rl.GamepadButtonLeftFaceUp,
+	"PadDown":       rl.GamepadButtonLeftFaceDown,
+	"PadLeft":       rl.GamepadButtonLeftFaceLeft,
+	"PadRight":      rl.GamepadButtonLeftFaceRight,
+	"PadLB":         rl.GamepadButtonLeftTrigger1,
+	"PadRB":         rl.GamepadButtonRightTrigger1,
+	"PadBack":       rl.GamepadButtonMiddleLeft,
+	"PadStart":      rl.GamepadButtonMiddleRight,
+	"PadLeftStick":  rl.GamepadButtonLeftThumb,
+	"PadRightStick": rl.GamepadButtonRightThumb,
+}
+
+var padAxisNames = map[string]int32{
+	"PadLeftX":  rl.GamepadAxisLeftX,
+	"PadLeftY":  rl.GamepadAxisLeftY,
+	"PadRightX": rl.GamepadAxisRightX,
+	"PadRightY": rl.GamepadAxisRightY,
+	"PadLT":     rl.GamepadAxisLeftTrigger,
+	"PadRT":     rl.GamepadAxisRightTrigger,
+}
+
+// inputName is the name of the key or mouse button in the settings file,
+// empty if it cannot be bound.
+func inputName(d device, code int32) string {
+	names := keyNames
+	if d == mouse {
+		names = mouseButtonNames
+	}
+	for name, c := range names {
+		if c == code {
+			return name
+		}
+	}
+	return ""
+}
+
+// binding is one input bound to an action.
+type binding struct {
+	device device
+	code   int32
+	// sign is -1 or 1 for a key or button pushing an axis, 0 otherwise.
+	sign float32
+}
+
+// controls reads the actions from the inputs bound to them.
+type controls struct {
+	bindings [][]binding
+	deadzone float32
+	// wasDown is whether every action was held on the previous frame, to
+	// tell when a trigger is pulled.
+	wasDown []bool
+}
+
+// newControls resolves the bindings of s. A name that is not an input is
+// logged and left out, and an action left with nothing keeps its default
+// bindings.
+func newControls(s *settings.File) *controls {
+	c := &controls{
+		bindings: make([][]binding, len(settings.Actions)),
+		deadzone: s.Deadzone,
+		wasDown:  make([]bool, len(settings.Actions)),
+	}
+	defaults := settings.Defaults().Bindings
+	for i, action := range settings.Actions {
+		c.bindings[i] = resolve(action, s.Bindings[action])
+		if len(c.bindings[i]) == 0 {
+			c.bindings[i] = resolve(action, defaults[action])
+		}
+	}
+	return c
+}
+
+func resolve(action string, names []string) []binding {
+	var bindings []binding
+	for _, name := range names {
+		sign, input := settings.Split(name)
+		b := binding{}
+		switch sign {
+		case "-":
+			b.sign = -1
+		case "+":
+			b.sign = 1
+		}
+		var ok bool
+		if b.code, ok = keyNames[input]; ok {
+			b.device = keyboard
+		} else if b.code, ok = mouseButtonNames[input]; ok {
+			b.device = mouse
+		} else if b.code, ok = padButtonNames[input]; ok {
+			b.device = padButton
+		} else if b.code, ok = padAxisNames[input]; ok {
+			b.device = padAxis
+		} else {
+			log.Printf("unknown input %q for %s, leaving it out", input, action)
+			continue
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings
+}
+
+// value is how far b is pushed, between -1 and 1 for a stick and between 0
+// and 1 for everything else.
+func (c *controls) value(b binding) float32 {
+	switch b.device {
+	case keyboard:
+		if rl.IsKeyDown(b.code) {
+			return 1
+		}
+	case mouse:
+		if rl.IsMouseButtonDown(rl.MouseButton(b.code)) {
+			return 1
+		}
+	case padButton:
+		if rl.IsGamepadAvailable(gamepad) && rl.IsGamepadButtonDown(gamepad, b.code) {
+			return 1
+		}
+	case padAxis:
+		if !rl.IsGamepadAvailable(gamepad) {
+			return 0
+		}
+		if b.code == rl.GamepadAxisLeftTrigger || b.code == rl.GamepadAxisRightTrigger {
+			// triggers go from -1 when released to 1 when pulled all the way
+			return (rl.GetGamepadAxisMovement(gamepad, b.code) + 1) / 2
+		}
+		return c.stick(b.code)
+	}
+	return 0
+}
+
+// stick reads one axis of a stick. The deadzone is round, so a stick
+// pushed slightly along one axis does not drift along the other.
+func (c *controls) stick(axis int32) float32 {
+	x, y := int32(rl.GamepadAxisLeftX), int32(rl.GamepadAxisLeftY)
+	if axis == rl.GamepadAxisRightX || axis == rl.GamepadAxisRightY {
+		x, y = rl.GamepadAxisRightX, rl.GamepadAxisRightY
+	}
+	push := rl.Vector2{X: rl.GetGamepadAxisMovement(gamepad, x), Y: rl.GetGamepadAxisMovement(gamepad, y)}
+	length := rl.Vector2Length(push)
+	if length <= c.deadzone {
+		return 0
+	}
+	// the push grows from 0 at the edge of the deadzone
+	scale := min((length-c.deadzone)/(1-c.deadzone), 1) / length
+	if axis == x {
+		return push.X * scale
+	}
+	return push.Y * scale
+}
+
+// held reports whether a key or button pushes axis in the direction of
+// sign.
+func (c *controls) held(axis Action, sign float32) bool {
+	for _, b := range c.bindings[axis] {
+		if b.sign == sign && c.value(b) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// analog is how far the sticks bound to axis push it.
+func (c *controls) analog(axis Action) float32 {
+	var v float32
+	for _, b := range c.bindings[axis] {
+		if b.device == padAxis {
+			v += c.value(b)
+		}
+	}
+	return rl.Clamp(v, -1, 1)
+}
+
+// vector is the push of the axes x and y, from keys and sticks alike.
+func (c *controls) vector(x, y Action) rl.Vector2 {
+	axis := func(a Action) float32 {
+		v := c.analog(a)
+		if c.held(a, -1) {
+			v--
+		}
+		if c.held(a, 1) {
+			v++
+		}
+		return rl.Clamp(v, -1, 1)
+	}
+	return rl.Vector2{X: axis(x), Y: axis(y)}
+}
+
+// down reports whether the button action is held.
+func (c *controls) down(a Action) bool {
+	for _, b := range c.bindings[a] {
+		if b.device == padAxis {
+			if c.value(b) > triggerThreshold {
+				return true
+			}
+		} else if c.value(b) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// pressed reports whether the button action went down this frame.
+func (c *controls) pressed(a Action) bool {
+	for _, b := range c.bindings[a] {
+		switch b.device {
+		case keyboard:
+			if rl.IsKeyPressed(b.code) {
+				return true
+			}
+		case mouse:
+			if rl.IsMouseButtonPressed(rl.MouseButton(b.code)) {
+				return true
+			}
+		case padButton:
+			if rl.IsGamepadAvailable(gamepad) && rl.IsGamepadButtonPressed(gamepad, b.code) {
+				return true
+			}
+		}
+	}
+	// triggers have no press of their own
+	return c.down(a) && !c.wasDown[a]
+}
+
+// endFrame remembers what was held for the pressed checks of the next
+// frame. The game calls it once per frame.
+func (c *controls) endFrame() {
+	for a := range c.wasDown {
+		c.wasDown[a] = c.down(Action(a))
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"brackeysGameJam/replay"
+	"brackeysGameJam/scores"
+	"brackeysGameJam/settings"
+	"brackeysGameJam/sim"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"log"
+	"time"
+)
+
+// Scene is one state of the game flow. The Game calls Enter when the scene
+// becomes current, then Update and Draw once per rendered frame until the
+// scene asks for another one, and Exit on the way out.
+type Scene interface {
+	Enter(g *Game)
+	Update(g *Game)
+	Draw(g *Game)
+	Exit(g *Game)
+}
+
+// Assets are the textures and sounds the scenes draw and play.
+type Assets struct {
+	button      rl.Texture2D
+	start       rl.Texture2D
+	backgrounds map[string]rl.Texture2D
+	sprites     Sprites
+
+	bgm            rl.Sound
+	loseSound      rl.Sound
+	winSound       rl.Sound
+	gunShot        rl.Sound
+	countdownSound rl.Sound
+}
+
+// Game owns everything that lives longer than one scene: the world, where
+// its input comes from and the run timer.
+type Game struct {
+	assets Assets
+	// canvas is what the scenes draw on, at the size of the arena. layout
+	// places UI on it, and screen is where it is drawn in the window.
+	canvas rl.RenderTexture2D
+	layout Layout
+	screen rl.Rectangle
+
+	arenaWidth  float32
+	arenaHeight float32
+	stages      []sim.StageDef
+	// customStages is set when stage files were loaded from disk, whose
+	// runs must not count towards the records of the campaign.
+	customStages bool
+	nextSeed     func() int64
+	// playback is the replay being watched, nil when playing live.
+	playback *replay.Replay
+	// difficulty and mode are picked on the title screen for live runs.
+	difficulty sim.Difficulty
+	mode       sim.Mode
+
+	world     *sim.World
+	input     inputSource
+	recording *replay.Replay
+
+	// scores are the personal bests, saved to scoresPath. An empty path
+	// keeps them for this session only.
+	scores     *scores.File
+	scoresPath string
+
+	// settings are the player's options, saved to settingsPath. An empty
+	// path keeps them for this session only.
+	settings     *settings.File
+	settingsPath string
+	// controls read the actions bound in settings.
+	controls *controls
+	// debug outlines collision shapes over the sprites. F3 toggles it.
+	debug bool
+
+	scene     Scene
+	nextScene Scene
+	quit      bool
+}
+
+// ChangeScene switches to scene once the current Update returns.
+func (g *Game) ChangeScene(scene Scene) {
+	g.nextScene = scene
+}
+
+// Quit ends the game once the current Update returns.
+func (g *Game) Quit() {
+	g.quit = true
+}
+
+// Run drives the scenes until the window closes or a scene quits.
+func (g *Game) Run(first Scene) {
+	g.scene = first
+	g.screen = g.layout.fit(float32(rl.GetScreenWidth()), float32(rl.GetScreenHeight()))
+	g.scene.Enter(g)
+	for !rl.WindowShouldClose() {
+		// the window can change size at any time
+		g.screen = g.layout.fit(float32(rl.GetScreenWidth()), float32(rl.GetScreenHeight()))
+		g.scene.Update(g)
+		if g.quit {
+			g.scene.Exit(g)
+			return
+		}
+		if g.nextScene != nil {
+			g.scene.Exit(g)
+			g.scene = g.nextScene
+			g.nextScene = nil
+			g.scene.Enter(g)
+		}
+
+		rl.BeginTextureMode(g.canvas)
+		rl.ClearBackground(rl.DarkGray)
+		g.scene.Draw(g)
+		rl.EndTextureMode()
+
+		rl.BeginDrawing()
+		rl.ClearBackground(rl.Black)
+		// render textures are stored upside down
+		rl.DrawTexturePro(
+			g.canvas.Texture,
+			rl.Rectangle{Width: g.layout.Width, Height: -g.layout.Height},
+			g.screen,
+			rl.Vector2{},
+			0,
+			rl.White,
+		)
+		rl.EndDrawing()
+		g.controls.endFrame()
+	}
+}
+
+// mouse is where the mouse is on the canvas. Over the bars it is off the
+// canvas.
+func (g *Game) mouse() rl.Vector2 {
+	m := rl.GetMousePosition()
+	scale := g.screen.Width / g.layout.Width
+	return rl.Vector2{X: (m.X - g.screen.X) / scale, Y: (m.Y - g.screen.Y) / scale}
+}
+
+// startRun sets up a fresh world for a new run, live or from the replay
+// being watched, and starts recording it.
+func (g *Game) startRun() {
+	if g.playback != nil {
+		g.world = sim.NewWorld(g.playback.Width, g.playback.Height, g.playback.Seed, g.stages)
+		g.world.SetDifficulty(g.difficulty)
+		g.world.SetMode(g.mode)
+		g.input = &replayInput{playback: g.playback.Playback()}
+	} else {
+		g.world = sim.NewWorld(g.arenaWidth, g.arenaHeight, g.nextSeed(), g.stages)
+		g.world.SetDifficulty(g.difficulty)
+		g.world.SetMode(g.mode)
+		g.input = &liveInput{world: g.world, controls: g.controls, mouse: g.mouse}
+		g.recording = replay.New(g.world.Seed(), g.world.Width, g.world.Height, g.difficulty.Name, g.mode, g.stages)
+	}
+}
+
+// endRun stores the replay of the run that just ended.
+func (g *Game) endRun() {
+	if g.recording != nil {
+		saveReplay(g.recording)
+		g.recording = nil
+	}
+}
+
+// abandonRun drops the run in progress without keeping its replay.
+func (g *Game) abandonRun() {
+	g.recording = nil
+}
+
+// board is the records of the difficulty being played, nil while watching
+// a replay or playing stages from disk, which must not count.
+func (g *Game) board() *scores.Board {
+	if g.playback != nil || g.customStages {
+		return nil
+	}
+	return g.scores.Board(g.difficulty.Name)
+}
+
+// saveScores writes the records. A failure is only logged, like a replay
+// that cannot be saved.
+func (g *Game) saveScores() {
+	if g.scoresPath == "" {
+		return
+	}
+	if err := scores.Save(g.scoresPath, g.scores); err != nil {
+		log.Printf("failed to save scores: %v", err)
+	}
+}
+
+// recordStageCleared records the stage just cleared and, after the last
+// one, the run. It reports whether the run is a new best.
+func (g *Game) recordStageCleared() bool {
+	board := g.board()
+	if board == nil {
+		return false
+	}
+	now := time.Now()
+	board.StageCleared(g.world.Stage().Name, g.world.StageElapsed(), now, g.world.Seed())
+	newBest := false
+	if g.world.IsFinalStage() {
+		newBest = board.RunWon(g.world.Elapsed(), now, g.world.Seed())
+	}
+	g.saveScores()
+	return newBest
+}
+
+// recordDeath records how the run ended: a death in the stage in play, or
+// the final score of an endless run. It reports whether that score is a new
+// best.
+func (g *Game) recordDeath() bool {
+	board := g.board()
+	if board == nil {
+		return false
+	}
+	newBest := false
+	if g.world.Mode() == sim.Endless {
+		newBest = board.EndlessOver(g.world.Score(), g.world.StageElapsed(), time.Now(), g.world.Seed())
+	} else {
+		board.Died(g.world.Stage().Name)
+	}
+	g.saveScores()
+	return newBest
+}
+
+// applySettings puts the options that take effect right away to use. The
+// window mode, resolution and vsync only apply at startup.
+func (g *Game) applySettings() {
+	rl.SetTargetFPS(int32(g.settings.FPS))
+	rl.SetMasterVolume(g.settings.MasterVolume)
+	rl.SetSoundVolume(g.assets.bgm, g.settings.MusicVolume)
+	for _, sound := range []rl.Sound{g.assets.loseSound, g.assets.winSound, g.assets.gunShot, g.assets.countdownSound} {
+		rl.SetSoundVolume(sound, g.settings.SFXVolume)
+	}
+	g.controls = newControls(g.settings)
+	if live, ok := g.input.(*liveInput); ok {
+		live.controls = g.controls
+	}
+}
+
+// saveSettings writes the options. A failure is only logged, like scores
+// that cannot be saved.
+func (g *Game) saveSettings() {
+	if g.settingsPath == "" {
+		return
+	}
+	if err := settings.Save(g.settingsPath, g.settings); err != nil {
+		log.Printf("failed to save settings: %v", err)
+	}
+}
@@ -2,9 +2,10 @@ module brackeysGameJam
 
 go 1.24.0
 
+require github.com/gen2brain/raylib-go/raylib v0.0.0-20250215042252-db8e47f0e5c5
+
 require (
 	github.com/ebitengine/purego v0.8.2 // indirect
-	github.com/gen2brain/raylib-go/raylib v0.0.0-20250215042252-db8e47f0e5c5 // indirect
 	golang.org/x/exp v0.0.0-20250215185904-eff6e970281f // indirect
 	golang.org/x/sys v0.30.0 // indirect
 )
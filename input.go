@@ -0,0 +1,138 @@
+package main
+
+import (
+	"brackeysGameJam/config"
+	"brackeysGameJam/replay"
+	"brackeysGameJam/sim"
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"log"
+	"time"
+)
+
+// inputSource feeds the simulation one step of input at a time, either from
+// the player's controls or from a recorded replay.
+type inputSource interface {
+	// Poll is called once per rendered frame.
+	Poll()
+	// Next is called once per simulation step. It returns false when there
+	// is no more input, which only happens at the end of a replay.
+	Next() (sim.Input, bool)
+}
+
+// stickReach is how far from the player the right stick puts the aim. Only
+// the direction matters to the shots.
+const stickReach = 400
+
+type liveInput struct {
+	// world is read to find the weapon in hand when the wheel turns, and
+	// where the player is when aiming with a stick.
+	world    *sim.World
+	controls *controls
+	// mouse is where the mouse is on the canvas, which is the arena.
+	mouse          func() rl.Vector2
+	firePending    bool
+	dashPending    bool
+	reloadPending  bool
+	restartPending bool
+	// weaponPending is the slot picked since the last step, 0 for none.
+	weaponPending int
+	// stickAim is the direction the aim stick last pointed in. It aims
+	// until the mouse moves, zero while the mouse aims.
+	stickAim rl.Vector2
+}
+
+// weaponKeys pick the weapon in the slot of the same number.
+var weaponKeys = []int32{rl.KeyOne, rl.KeyTwo, rl.KeyThree, rl.KeyFour, rl.KeyFive, rl.KeySix, rl.KeySeven, rl.KeyEight, rl.KeyNine}
+
+func (l *liveInput) Poll() {
+	// a click between two steps must not be lost on fast monitors
+	l.firePending = l.firePending || l.controls.pressed(Fire)
+	l.dashPending = l.dashPending || l.controls.pressed(Dash)
+	l.reloadPending = l.reloadPending || l.controls.pressed(Reload)
+
+	if aim := l.controls.vector(AimX, AimY); aim != (rl.Vector2{}) {
+		l.stickAim = rl.Vector2Normalize(aim)
+	} else if rl.GetMouseDelta() != (rl.Vector2{}) {
+		l.stickAim = rl.Vector2{}
+	}
+
+	for i, key := range weaponKeys[:min(len(weaponKeys), len(sim.Weapons))] {
+		if rl.IsKeyPressed(key) {
+			l.weaponPending = i + 1
+		}
+	}
+	if wheel := rl.GetMouseWheelMove(); wheel != 0 {
+		slot := l.weaponPending
+		if slot == 0 {
+			slot = l.world.Player().WeaponSlot()
+		}
+		// scrolling down picks the next weapon, wrapping around
+		if wheel < 0 {
+			slot = slot%len(sim.Weapons) + 1
+		} else {
+			slot = (slot+len(sim.Weapons)-2)%len(sim.Weapons) + 1
+		}
+		l.weaponPending = slot
+	}
+}
+
+func (l *liveInput) Next() (sim.Input, bool) {
+	c := l.controls
+	in := sim.Input{
+		// keys keep their own movement, the sticks only move the player
+		// while no key does
+		Up:    c.held(MoveY, -1),
+		Left:  c.held(MoveX, -1),
+		Down:  c.held(MoveY, 1),
+		Right: c.held(MoveX, 1),
+		Move:  sim.Vector2{X: c.analog(MoveX), Y: c.analog(MoveY)},
+		Aim:   sim.Vector2(l.mouse()),
+		Fire:  l.firePending || c.down(Fire),
+	}
+	if l.stickAim != (rl.Vector2{}) {
+		centre := l.world.Player().Shape().Centroid()
+		in.Aim = sim.Vector2{X: centre.X + l.stickAim.X*stickReach, Y: centre.Y + l.stickAim.Y*stickReach}
+	}
+	in.Dash = l.dashPending
+	in.Reload = l.reloadPending
+	in.Weapon = l.weaponPending
+	in.RestartStage = l.restartPending
+	l.firePending = false
+	l.dashPending = false
+	l.reloadPending = false
+	l.weaponPending = 0
+	l.restartPending = false
+	return in, true
+}
+
+// restartStage asks the simulation to restart the stage on the next step.
+func (l *liveInput) restartStage() {
+	l.restartPending = true
+}
+
+type replayInput struct {
+	playback *replay.Playback
+}
+
+func (r *replayInput) Poll() {
+}
+
+func (r *replayInput) Next() (sim.Input, bool) {
+	return r.playback.Next()
+}
+
+// saveReplay stores a finished run next to the other replays. A failure is
+// only logged; it must never take the game down.
+func saveReplay(recording *replay.Replay) {
+	path, err := config.Path("replays", fmt.Sprintf("%s-%d.ckr", time.Now().Format("20060102-150405"), recording.Seed))
+	if err != nil {
+		log.Printf("failed to save replay: %v", err)
+		return
+	}
+	if err := replay.Save(path, recording); err != nil {
+		log.Printf("failed to save replay: %v", err)
+		return
+	}
+	log.Printf("replay saved to %s", path)
+}
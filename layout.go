@@ -0,0 +1,66 @@
+package main
+
+import rl "github.com/gen2brain/raylib-go/raylib"
+
+// virtualWidth and virtualHeight are the size of the canvas live runs are
+// drawn on. The canvas is scaled to fit the window with bars where the
+// shapes differ, so the arena and the screens look the same on any monitor.
+const (
+	virtualWidth  = 1920
+	virtualHeight = 1080
+)
+
+// Anchor is a point of a box in fractions of its size: 0, 0 is its top left
+// corner and 1, 1 its bottom right one.
+type Anchor struct {
+	X, Y float32
+}
+
+var (
+	TopLeft     = Anchor{0, 0}
+	Top         = Anchor{0.5, 0}
+	TopRight    = Anchor{1, 0}
+	Left        = Anchor{0, 0.5}
+	Center      = Anchor{0.5, 0.5}
+	Right       = Anchor{1, 0.5}
+	BottomLeft  = Anchor{0, 1}
+	Bottom      = Anchor{0.5, 1}
+	BottomRight = Anchor{1, 1}
+)
+
+// Layout places UI on the canvas by anchors and offsets from them, so a
+// screen holds together whatever the canvas size is.
+type Layout struct {
+	Width, Height float32
+}
+
+// Point is anchor of the canvas moved by dx, dy.
+func (l Layout) Point(anchor Anchor, dx, dy float32) rl.Vector2 {
+	return rl.Vector2{X: l.Width*anchor.X + dx, Y: l.Height*anchor.Y + dy}
+}
+
+// Place is the top left corner of a width by height box whose pivot sits
+// at anchor of the canvas moved by dx, dy.
+func (l Layout) Place(anchor Anchor, dx, dy float32, pivot Anchor, width, height float32) rl.Vector2 {
+	p := l.Point(anchor, dx, dy)
+	return rl.Vector2{X: p.X - width*pivot.X, Y: p.Y - height*pivot.Y}
+}
+
+// Text draws text with its pivot at anchor of the canvas moved by dx, dy.
+// Top as the pivot centres a line below the point.
+func (l Layout) Text(text string, anchor Anchor, dx, dy float32, pivot Anchor, fontSize int32, color rl.Color) {
+	p := l.Place(anchor, dx, dy, pivot, float32(rl.MeasureText(text, fontSize)), float32(fontSize))
+	rl.DrawText(text, int32(p.X), int32(p.Y), fontSize, color)
+}
+
+// fit is where a canvas of the layout's size goes in the window: as large
+// as fits, centred, with bars on the sides or at the top and bottom.
+func (l Layout) fit(screenWidth, screenHeight float32) rl.Rectangle {
+	scale := min(screenWidth/l.Width, screenHeight/l.Height)
+	return rl.Rectangle{
+		X:      (screenWidth - l.Width*scale) / 2,
+		Y:      (screenHeight - l.Height*scale) / 2,
+		Width:  l.Width * scale,
+		Height: l.Height * scale,
+	}
+}
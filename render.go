@@ -0,0 +1,277 @@
+package main
+
+import (
+	"brackeysGameJam/sim"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"time"
+)
+
+// Sprites holds the textures the front end uses to draw the simulation.
+type Sprites struct {
+	bullet      rl.Texture2D
+	enemy       rl.Texture2D
+	playerBack  rl.Texture2D
+	playerFront rl.Texture2D
+	playerLeft  rl.Texture2D
+	playerRight rl.Texture2D
+}
+
+// DrawGameObjects draws every object alpha of the way between its previous
+// and current simulation step.
+func DrawGameObjects(world *sim.World, sprites Sprites, alpha float32) {
+	for _, obj := range world.Objects() {
+		position := spritePosition(obj, alpha)
+		switch o := obj.(type) {
+		case *sim.Player:
+			// blink through the grace period after a hit
+			if o.IsInvulnerable() && o.InvulnerableLeft()/(time.Duration(100)*time.Millisecond)%2 == 1 {
+				continue
+			}
+			drawPlayer(o, position, sprites)
+		case *sim.Enemy:
+			drawEnemy(o, position, sprites)
+		case *sim.Bullet:
+			drawBullet(o, position, sprites)
+		}
+	}
+}
+
+func interpolate(obj sim.GameObject, alpha float32) rl.Vector2 {
+	prev := obj.PrevPosition()
+	cur := obj.Position()
+	return rl.Vector2{
+		X: prev.X + (cur.X-prev.X)*alpha,
+		Y: prev.Y + (cur.Y-prev.Y)*alpha,
+	}
+}
+
+// spritePosition is where obj's sprite goes alpha of the way between its
+// previous and current simulation step.
+func spritePosition(obj sim.GameObject, alpha float32) rl.Vector2 {
+	position := interpolate(obj, alpha)
+	sprite := obj.SpriteRect()
+	current := obj.Position()
+	return rl.Vector2{
+		X: position.X + sprite.X - current.X,
+		Y: position.Y + sprite.Y - current.Y,
+	}
+}
+
+// DrawCollisionShapes outlines what every object collides with, moved along
+// with the interpolated sprites so the two can be compared.
+func DrawCollisionShapes(world *sim.World, alpha float32) {
+	for _, o := range world.Obstacles() {
+		rl.DrawRectangleLinesEx(rl.Rectangle(o.Rect), 2, rl.Blue)
+	}
+	for _, obj := range world.Objects() {
+		position := interpolate(obj, alpha)
+		current := obj.Position()
+		dx := position.X - current.X
+		dy := position.Y - current.Y
+
+		color := rl.Green
+		if obj.IsEnemy() {
+			color = rl.Red
+		} else if obj.IsBullet() {
+			color = rl.Yellow
+		}
+
+		shape := obj.Shape()
+		switch shape.Kind {
+		case sim.ShapeCircle:
+			rl.DrawCircleLines(int32(shape.Center.X+dx), int32(shape.Center.Y+dy), shape.Radius, color)
+		default:
+			rect := rl.Rectangle(shape.Rect)
+			rect.X += dx
+			rect.Y += dy
+			rl.DrawRectangleLinesEx(rect, 2, color)
+		}
+	}
+}
+
+// DrawObstacles draws the rocks, ice walls and trees of the stage.
+func DrawObstacles(world *sim.World) {
+	for _, o := range world.Obstacles() {
+		rect := rl.Rectangle(o.Rect)
+		switch o.Kind {
+		case &sim.IceWall:
+			rl.DrawRectangleRec(rect, rl.Color{R: 200, G: 235, B: 255, A: 200})
+			rl.DrawRectangleLinesEx(rect, 4, rl.Color{R: 120, G: 190, B: 230, A: 255})
+		case &sim.Tree:
+			trunk := rl.Rectangle{X: rect.X + rect.Width*0.4, Y: rect.Y + rect.Height*0.5, Width: rect.Width * 0.2, Height: rect.Height * 0.5}
+			rl.DrawRectangleRec(trunk, rl.Brown)
+			center := rl.Vector2{X: rect.X + rect.Width/2, Y: rect.Y + rect.Height*0.4}
+			rl.DrawCircleV(center, min(rect.Width, rect.Height)*0.45, rl.DarkGreen)
+		default:
+			rl.DrawRectangleRounded(rect, 0.4, 8, rl.Gray)
+			rl.DrawRectangleRoundedLinesEx(rect, 0.4, 8, 4, rl.DarkGray)
+		}
+	}
+}
+
+func DrawDeadObjects(world *sim.World, sprites Sprites) {
+	for _, obj := range world.DeadObjects() {
+		sprite := obj.SpriteRect()
+		drawSprite(
+			sprites.enemy,
+			sprite,
+			rl.Vector2{X: sprite.X, Y: sprite.Y},
+			rl.Color{
+				R: 0,
+				G: 0,
+				B: 0,
+				A: 30,
+			},
+		)
+	}
+}
+
+// drawPlayer turns the hero to where it aims, with a mouse or a stick alike.
+func drawPlayer(p *sim.Player, texturePosition rl.Vector2, sprites Sprites) {
+	sprite := p.SpriteRect()
+	aim := p.Aim()
+	if aim == (sim.Vector2{}) {
+		// it has not aimed yet, so it faces the camera
+		aim.Y = 1
+	}
+
+	angle := math.Atan2(float64(aim.Y), float64(aim.X)) * (180 / math.Pi)
+	if angle < 0 {
+		angle += 360
+	}
+
+	var texture rl.Texture2D
+
+	if angle >= 45 && angle < 135 {
+		texture = sprites.playerFront
+	} else if angle >= 135 && angle < 225 {
+		texture = sprites.playerLeft
+	} else if angle >= 225 && angle < 315 {
+		texture = sprites.playerBack
+	} else {
+		texture = sprites.playerRight
+	}
+
+	rl.DrawTextureRec(
+		texture,
+		rl.Rectangle{
+			Width:  sprite.Width,
+			Height: sprite.Height,
+		},
+		texturePosition,
+		rl.White,
+	)
+}
+
+// drawSprite draws the whole of texture scaled into the size of sprite at
+// position, since enemies of different archetypes share one texture.
+func drawSprite(texture rl.Texture2D, sprite sim.Rectangle, position rl.Vector2, tint rl.Color) {
+	rl.DrawTexturePro(
+		texture,
+		rl.Rectangle{X: 0, Y: 0, Width: float32(texture.Width), Height: float32(texture.Height)},
+		rl.Rectangle{X: position.X, Y: position.Y, Width: sprite.Width, Height: sprite.Height},
+		rl.Vector2{},
+		0,
+		tint,
+	)
+}
+
+func drawEnemy(e *sim.Enemy, position rl.Vector2, sprites Sprites) {
+	sprite := e.SpriteRect()
+	tint := rl.Color(e.Archetype().Tint)
+	if e.IsRushing() {
+		tint = rl.Color{
+			R: 255,
+			G: 100,
+			B: 100,
+			A: 255,
+		}
+	}
+	if e.IsWindingUp() {
+		drawDashWarning(e, position, sprites)
+	}
+	if e.IsSpawning() {
+		drawSpawnIn(e, position, sprites, tint)
+		return
+	}
+	if e.IsDying() {
+		drawDefeat(e, position, sprites, tint)
+		return
+	}
+	drawSprite(sprites.enemy, sprite, position, tint)
+	if e.IsFlashing() {
+		// drawing the sprite a second time additively washes it out to white
+		rl.BeginBlendMode(rl.BlendAdditive)
+		drawSprite(sprites.enemy, sprite, position, rl.White)
+		rl.EndBlendMode()
+	}
+}
+
+// drawSpawnIn fades an appearing enemy in while a ring closes in on it.
+func drawSpawnIn(e *sim.Enemy, position rl.Vector2, sprites Sprites, tint rl.Color) {
+	sprite := e.SpriteRect()
+	progress := e.SpawnProgress()
+	center := rl.Vector2{X: position.X + sprite.Width/2, Y: position.Y + sprite.Height/2}
+	radius := sprite.Width * (1.5 - progress)
+	rl.DrawRing(center, radius-4, radius, 0, 360, 32, rl.Fade(tint, 0.8))
+	drawSprite(sprites.enemy, sprite, position, rl.Fade(tint, progress))
+}
+
+// drawDefeat shakes a defeated boss harder and harder while it flashes,
+// fades out and throws off rings.
+func drawDefeat(e *sim.Enemy, position rl.Vector2, sprites Sprites, tint rl.Color) {
+	sprite := e.SpriteRect()
+	progress := e.DefeatProgress()
+	shake := 4 + 20*progress
+	t := rl.GetTime() * 40
+	position.X += float32(math.Sin(t)) * shake
+	position.Y += float32(math.Cos(t*1.3)) * shake
+	center := rl.Vector2{X: position.X + sprite.Width/2, Y: position.Y + sprite.Height/2}
+	for i := float32(0); i < 3; i++ {
+		ring := float32(math.Mod(float64(progress*3+i/3), 1))
+		radius := sprite.Width * (0.5 + ring*1.5)
+		rl.DrawRing(center, radius-6, radius, 0, 360, 48, rl.Fade(tint, 1-ring))
+	}
+	drawSprite(sprites.enemy, sprite, position, rl.Fade(tint, 1-progress))
+	if int(rl.GetTime()*12)%2 == 0 {
+		rl.BeginBlendMode(rl.BlendAdditive)
+		drawSprite(sprites.enemy, sprite, position, rl.Fade(rl.White, 1-progress))
+		rl.EndBlendMode()
+	}
+}
+
+// dashWarningLength is how far ahead of a winding up enemy its dash line
+// reaches.
+const dashWarningLength = 1600
+
+// dashWarningColor is the red of the outline and line of a winding up enemy.
+var dashWarningColor = rl.Color{R: 255, G: 40, B: 30, A: 200}
+
+// drawDashWarning draws the line an enemy is about to dash along and a
+// flashing outline around it, so a dash never comes out of nowhere.
+func drawDashWarning(e *sim.Enemy, position rl.Vector2, sprites Sprites) {
+	sprite := e.SpriteRect()
+	direction := e.DashDirection()
+	from := rl.Vector2{X: position.X + sprite.Width/2, Y: position.Y + sprite.Height/2}
+	to := rl.Vector2{X: from.X + direction.X*dashWarningLength, Y: from.Y + direction.Y*dashWarningLength}
+	rl.DrawLineEx(from, to, 6, dashWarningColor)
+
+	if int(rl.GetTime()*10)%2 == 0 {
+		return
+	}
+	// the sprite's silhouette, nudged out in four directions, shows around
+	// the sprite drawn on top as an outline
+	const outline = 5
+	for _, offset := range []rl.Vector2{{X: -outline}, {X: outline}, {Y: -outline}, {Y: outline}} {
+		drawSprite(sprites.enemy, sprite, rl.Vector2{X: position.X + offset.X, Y: position.Y + offset.Y}, dashWarningColor)
+	}
+}
+
+func drawBullet(b *sim.Bullet, position rl.Vector2, sprites Sprites) {
+	tint := rl.Yellow
+	if b.IsHostile() {
+		tint = rl.Magenta
+	}
+	drawSprite(sprites.bullet, b.SpriteRect(), position, tint)
+}
@@ -0,0 +1,382 @@
+// Package replay records the input of a run and plays it back. Together with
+// the run seed this reproduces the run exactly, because the simulation is
+// deterministic.
+package replay
+
+import (
+	"brackeysGameJam/config"
+	"brackeysGameJam/sim"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// formatVersion is bumped whenever the file layout changes. Older versions
+// still load:
+//   - 2 added the restart stage flag, version 1 files never set it.
+//   - 3 added the difficulty, older files were all played with one hit.
+//   - 4 added the reload flag and the weapon slot byte to every step.
+//   - 5 added the mode, older files were all campaign runs.
+//   - 6 added the dash flag and the stick push to every step.
+//   - 7 added the hash of the stages, older files are played on whatever
+//     stages are loaded.
+const formatVersion uint16 = 7
+
+var magic = [4]byte{'C', 'K', 'R', 'P'}
+
+const (
+	flagUp = 1 << iota
+	flagLeft
+	flagDown
+	flagRight
+	flagFire
+	flagRestartStage
+	flagReload
+	flagDash
+)
+
+// stepSize is the length of one step in the current format.
+const stepSize = 18
+
+// maxSteps caps the step count a replay may claim: a day of play.
+const maxSteps = 24 * 60 * 60 * uint32(time.Second/sim.FixedStep)
+
+// Header is what the simulation needs, besides the input, to replay a run.
+type Header struct {
+	GameVersion string
+	Seed        int64
+	Width       float32
+	Height      float32
+	// Difficulty is the name of a sim.Difficulty.
+	Difficulty string
+	// Mode is the name of a sim.Mode.
+	Mode string
+	// Stages is the StagesHash of the stages the run was played on, empty
+	// before version 7.
+	Stages string
+}
+
+// Replay is a header followed by the input of every simulation step.
+type Replay struct {
+	Header
+	Inputs []sim.Input
+}
+
+func New(seed int64, width, height float32, difficulty string, mode sim.Mode, stages []sim.StageDef) *Replay {
+	return &Replay{
+		Header: Header{
+			GameVersion: sim.Version,
+			Seed:        seed,
+			Width:       width,
+			Height:      height,
+			Difficulty:  difficulty,
+			Mode:        string(mode),
+			Stages:      StagesHash(stages),
+		},
+	}
+}
+
+// StagesHash identifies a set of stages, so a run is only played back on
+// the stages it was recorded on.
+func StagesHash(stages []sim.StageDef) string {
+	// stages come from JSON, so they always encode back to it
+	data, _ := json.Marshal(stages)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// DifficultyRule looks up the difficulty the run was played with.
+func (h Header) DifficultyRule() (sim.Difficulty, error) {
+	d, ok := sim.DifficultyByName(h.Difficulty)
+	if !ok {
+		return sim.Difficulty{}, fmt.Errorf("unknown difficulty %q", h.Difficulty)
+	}
+	return d, nil
+}
+
+// GameMode looks up the mode the run was played in.
+func (h Header) GameMode() (sim.Mode, error) {
+	m, ok := sim.ModeByName(h.Mode)
+	if !ok {
+		return "", fmt.Errorf("unknown mode %q", h.Mode)
+	}
+	return m, nil
+}
+
+// CheckStages reports whether the run was recorded on stages. A run
+// recorded on other stages goes out of sync at once. Files from before
+// version 7 cannot tell and always pass.
+func (h Header) CheckStages(stages []sim.StageDef) error {
+	if h.Stages != "" && h.Stages != StagesHash(stages) {
+		return errors.New("recorded on other stages than the ones loaded, load the stage files it was recorded with")
+	}
+	return nil
+}
+
+// Record appends the input of one simulation step.
+func (r *Replay) Record(in sim.Input) {
+	r.Inputs = append(r.Inputs, in)
+}
+
+// Write encodes r as a small uncompressed header followed by the gzipped
+// steps. Each step is a flags byte, the aim position and the weapon slot.
+func Write(w io.Writer, r *Replay) error {
+	if len(r.GameVersion) > math.MaxUint8 {
+		return fmt.Errorf("game version %q is too long", r.GameVersion)
+	}
+	if len(r.Difficulty) > math.MaxUint8 {
+		return fmt.Errorf("difficulty %q is too long", r.Difficulty)
+	}
+	if len(r.Mode) > math.MaxUint8 {
+		return fmt.Errorf("mode %q is too long", r.Mode)
+	}
+	if len(r.Stages) > math.MaxUint8 {
+		return fmt.Errorf("stages hash %q is too long", r.Stages)
+	}
+	bw := bufio.NewWriter(w)
+	header := []any{
+		magic,
+		formatVersion,
+		uint8(len(r.GameVersion)),
+		[]byte(r.GameVersion),
+		r.Seed,
+		r.Width,
+		r.Height,
+		uint8(len(r.Difficulty)),
+		[]byte(r.Difficulty),
+		uint8(len(r.Mode)),
+		[]byte(r.Mode),
+		uint8(len(r.Stages)),
+		[]byte(r.Stages),
+		uint32(len(r.Inputs)),
+	}
+	for _, v := range header {
+		if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	zw := gzip.NewWriter(bw)
+	step := make([]byte, stepSize)
+	for _, in := range r.Inputs {
+		if in.Weapon < 0 || in.Weapon > math.MaxUint8 {
+			return fmt.Errorf("weapon slot %d does not fit in a byte", in.Weapon)
+		}
+		var flags byte
+		if in.Up {
+			flags |= flagUp
+		}
+		if in.Left {
+			flags |= flagLeft
+		}
+		if in.Down {
+			flags |= flagDown
+		}
+		if in.Right {
+			flags |= flagRight
+		}
+		if in.Fire {
+			flags |= flagFire
+		}
+		if in.RestartStage {
+			flags |= flagRestartStage
+		}
+		if in.Reload {
+			flags |= flagReload
+		}
+		if in.Dash {
+			flags |= flagDash
+		}
+		step[0] = flags
+		binary.LittleEndian.PutUint32(step[1:], math.Float32bits(in.Aim.X))
+		binary.LittleEndian.PutUint32(step[5:], math.Float32bits(in.Aim.Y))
+		step[9] = uint8(in.Weapon)
+		binary.LittleEndian.PutUint32(step[10:], math.Float32bits(in.Move.X))
+		binary.LittleEndian.PutUint32(step[14:], math.Float32bits(in.Move.Y))
+		if _, err := zw.Write(step); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Read decodes a replay written by Write.
+func Read(rd io.Reader) (*Replay, error) {
+	br := bufio.NewReader(rd)
+	var fileMagic [4]byte
+	if err := binary.Read(br, binary.LittleEndian, &fileMagic); err != nil {
+		return nil, fmt.Errorf("reading replay header: %w", err)
+	}
+	if fileMagic != magic {
+		return nil, errors.New("not a replay file")
+	}
+	var version uint16
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil {
+		return nil, fmt.Errorf("reading replay header: %w", err)
+	}
+	if version == 0 || version > formatVersion {
+		return nil, fmt.Errorf("unsupported replay format version %d, want at most %d", version, formatVersion)
+	}
+
+	gameVersion, err := readString(br)
+	if err != nil {
+		return nil, fmt.Errorf("reading replay header: %w", err)
+	}
+	r := &Replay{Header: Header{GameVersion: gameVersion}}
+	for _, v := range []any{&r.Seed, &r.Width, &r.Height} {
+		if err := binary.Read(br, binary.LittleEndian, v); err != nil {
+			return nil, fmt.Errorf("reading replay header: %w", err)
+		}
+	}
+	r.Difficulty = sim.OneHit.Name
+	if version >= 3 {
+		difficulty, err := readString(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading replay header: %w", err)
+		}
+		r.Difficulty = difficulty
+	}
+	r.Mode = string(sim.Campaign)
+	if version >= 5 {
+		mode, err := readString(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading replay header: %w", err)
+		}
+		r.Mode = mode
+	}
+	if version >= 7 {
+		stages, err := readString(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading replay header: %w", err)
+		}
+		r.Stages = stages
+	}
+	var count uint32
+	if err := binary.Read(br, binary.LittleEndian, &count); err != nil {
+		return nil, fmt.Errorf("reading replay header: %w", err)
+	}
+
+	if count > maxSteps {
+		return nil, fmt.Errorf("reading replay header: %d steps is more than a run can take, want at most %d", count, maxSteps)
+	}
+
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("reading replay steps: %w", err)
+	}
+	defer zr.Close()
+	// the count is not trusted until the steps are there, so the slice
+	// only grows with what is actually read
+	r.Inputs = make([]sim.Input, 0, min(count, 1<<16))
+	size := stepSize
+	switch {
+	case version < 4:
+		size = 9
+	case version < 6:
+		size = 10
+	}
+	step := make([]byte, size)
+	for i := uint32(0); i < count; i++ {
+		if _, err := io.ReadFull(zr, step); err != nil {
+			return nil, fmt.Errorf("reading replay step %d of %d: %w", i, count, err)
+		}
+		flags := step[0]
+		r.Inputs = append(r.Inputs, sim.Input{
+			Up:    flags&flagUp != 0,
+			Left:  flags&flagLeft != 0,
+			Down:  flags&flagDown != 0,
+			Right: flags&flagRight != 0,
+			Fire:  flags&flagFire != 0,
+			// always clear in version 1 files
+			RestartStage: flags&flagRestartStage != 0,
+			// always clear before version 4
+			Reload: flags&flagReload != 0,
+			// always clear before version 6
+			Dash: flags&flagDash != 0,
+			Aim: sim.Vector2{
+				X: math.Float32frombits(binary.LittleEndian.Uint32(step[1:])),
+				Y: math.Float32frombits(binary.LittleEndian.Uint32(step[5:])),
+			},
+		})
+		if version >= 4 {
+			r.Inputs[i].Weapon = int(step[9])
+		}
+		if version >= 6 {
+			r.Inputs[i].Move = sim.Vector2{
+				X: math.Float32frombits(binary.LittleEndian.Uint32(step[10:])),
+				Y: math.Float32frombits(binary.LittleEndian.Uint32(step[14:])),
+			}
+		}
+	}
+	return r, nil
+}
+
+// readString reads a string written as a length byte and the bytes.
+func readString(r io.Reader) (string, error) {
+	var n uint8
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Save writes r to path, creating the directory if needed. A crash halfway
+// leaves the old file intact.
+func Save(path string, r *Replay) error {
+	var buf bytes.Buffer
+	if err := Write(&buf, r); err != nil {
+		return err
+	}
+	return config.WriteFile(path, buf.Bytes())
+}
+
+func Load(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Playback hands out the recorded steps in order.
+type Playback struct {
+	replay *Replay
+	next   int
+}
+
+func (r *Replay) Playback() *Playback {
+	return &Playback{replay: r}
+}
+
+// Next returns the input of the next step, or false once the recording has
+// run out.
+func (p *Playback) Next() (sim.Input, bool) {
+	if p.next >= len(p.replay.Inputs) {
+		return sim.Input{}, false
+	}
+	in := p.replay.Inputs[p.next]
+	p.next++
+	return in, true
+}
@@ -0,0 +1,175 @@
+package replay
+
+import (
+	"brackeysGameJam/sim"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testStages = []sim.StageDef{{Name: "1", Background: "snow.png"}}
+
+func TestRoundTrip(t *testing.T) {
+	r := New(42, 1920, 1080, sim.ThreeLives.Name, sim.Endless, testStages)
+	r.Record(sim.Input{Up: true, Aim: sim.Vector2{X: 10.5, Y: -3}})
+	r.Record(sim.Input{Left: true, Fire: true, Weapon: 2})
+	r.Record(sim.Input{Down: true, Reload: true, Move: sim.Vector2{X: -0.25, Y: 1}})
+	r.Record(sim.Input{Right: true, Dash: true, Weapon: 3, Aim: sim.Vector2{X: 1e6, Y: 0.125}})
+	r.Record(sim.Input{RestartStage: true, Move: sim.Vector2{X: 0.7, Y: -0.7}})
+	r.Record(sim.Input{
+		Up: true, Left: true, Down: true, Right: true, Fire: true, Reload: true, Dash: true, RestartStage: true,
+		Weapon: 255, Aim: sim.Vector2{X: 5, Y: 6}, Move: sim.Vector2{X: 1, Y: -1},
+	})
+
+	var buf bytes.Buffer
+	if err := Write(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, r) {
+		t.Fatalf("read back\n%+v\nwant\n%+v", got, r)
+	}
+}
+
+// oldStep is one step of a file written before the current format.
+type oldStep struct {
+	flags  byte
+	aim    sim.Vector2
+	weapon byte
+	move   sim.Vector2
+}
+
+// encodeOld writes a replay the way format version wrote it.
+func encodeOld(t *testing.T, version uint16, steps []oldStep) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	header := []any{magic, version, uint8(len("1.0.0")), []byte("1.0.0"), int64(7), float32(800), float32(600)}
+	if version >= 3 {
+		header = append(header, uint8(len(sim.FiveLives.Name)), []byte(sim.FiveLives.Name))
+	}
+	if version >= 5 {
+		header = append(header, uint8(len(sim.Endless)), []byte(sim.Endless))
+	}
+	header = append(header, uint32(len(steps)))
+	for _, v := range header {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	size := 9
+	switch {
+	case version >= 6:
+		size = 18
+	case version >= 4:
+		size = 10
+	}
+	zw := gzip.NewWriter(&buf)
+	for _, s := range steps {
+		step := make([]byte, size)
+		step[0] = s.flags
+		binary.LittleEndian.PutUint32(step[1:], math.Float32bits(s.aim.X))
+		binary.LittleEndian.PutUint32(step[5:], math.Float32bits(s.aim.Y))
+		if version >= 4 {
+			step[9] = s.weapon
+		}
+		if version >= 6 {
+			binary.LittleEndian.PutUint32(step[10:], math.Float32bits(s.move.X))
+			binary.LittleEndian.PutUint32(step[14:], math.Float32bits(s.move.Y))
+		}
+		if _, err := zw.Write(step); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// oldSteps are steps a file of version can hold, with the inputs they
+// decode to: the restart flag came with version 2, reload and the weapon
+// slot with version 4, the dash and the stick push with version 6.
+func oldSteps(version uint16) ([]oldStep, []sim.Input) {
+	steps := []oldStep{
+		{flags: flagUp | flagFire, aim: sim.Vector2{X: 1, Y: 2}},
+		{flags: flagLeft | flagRight, aim: sim.Vector2{X: -3, Y: 4.5}},
+	}
+	want := []sim.Input{
+		{Up: true, Fire: true, Aim: sim.Vector2{X: 1, Y: 2}},
+		{Left: true, Right: true, Aim: sim.Vector2{X: -3, Y: 4.5}},
+	}
+	if version >= 2 {
+		steps = append(steps, oldStep{flags: flagRestartStage})
+		want = append(want, sim.Input{RestartStage: true})
+	}
+	if version >= 4 {
+		steps = append(steps, oldStep{flags: flagDown | flagReload, aim: sim.Vector2{X: 7, Y: 8}, weapon: 2})
+		want = append(want, sim.Input{Down: true, Reload: true, Aim: sim.Vector2{X: 7, Y: 8}, Weapon: 2})
+	}
+	if version >= 6 {
+		steps = append(steps, oldStep{flags: flagDash, move: sim.Vector2{X: 0.5, Y: -1}})
+		want = append(want, sim.Input{Dash: true, Move: sim.Vector2{X: 0.5, Y: -1}})
+	}
+	return steps, want
+}
+
+func TestReadOldVersions(t *testing.T) {
+	for _, tc := range []struct {
+		version    uint16
+		difficulty string
+		mode       string
+	}{
+		{version: 1, difficulty: sim.OneHit.Name, mode: string(sim.Campaign)},
+		{version: 2, difficulty: sim.OneHit.Name, mode: string(sim.Campaign)},
+		{version: 3, difficulty: sim.FiveLives.Name, mode: string(sim.Campaign)},
+		{version: 4, difficulty: sim.FiveLives.Name, mode: string(sim.Campaign)},
+		{version: 5, difficulty: sim.FiveLives.Name, mode: string(sim.Endless)},
+		{version: 6, difficulty: sim.FiveLives.Name, mode: string(sim.Endless)},
+	} {
+		steps, want := oldSteps(tc.version)
+		r, err := Read(bytes.NewReader(encodeOld(t, tc.version, steps)))
+		if err != nil {
+			t.Fatalf("version %d: %v", tc.version, err)
+		}
+		// nothing before version 7 knows its stages
+		wantHeader := Header{GameVersion: "1.0.0", Seed: 7, Width: 800, Height: 600, Difficulty: tc.difficulty, Mode: tc.mode}
+		if r.Header != wantHeader {
+			t.Errorf("version %d: header %+v, want %+v", tc.version, r.Header, wantHeader)
+		}
+		if !reflect.DeepEqual(r.Inputs, want) {
+			t.Errorf("version %d: inputs %+v, want %+v", tc.version, r.Inputs, want)
+		}
+	}
+}
+
+func TestCheckStages(t *testing.T) {
+	r := New(1, 800, 600, sim.OneHit.Name, sim.Campaign, testStages)
+	if err := r.CheckStages(testStages); err != nil {
+		t.Errorf("same stages: %v", err)
+	}
+	other := []sim.StageDef{{Name: "1", Background: "ice.png"}}
+	if err := r.CheckStages(other); err == nil {
+		t.Error("other stages pass, want an error")
+	}
+	r.Stages = ""
+	if err := r.CheckStages(other); err != nil {
+		t.Errorf("a file without a stages hash: %v", err)
+	}
+}
+
+func TestReadRejectsHugeCount(t *testing.T) {
+	data := encodeOld(t, 5, nil)
+	// the count sits right before the gzip stream
+	at := bytes.Index(data, []byte{0x1f, 0x8b}) - 4
+	binary.LittleEndian.PutUint32(data[at:], math.MaxUint32)
+	if _, err := Read(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "more than a run can take") {
+		t.Fatalf("error %v, want the count rejected", err)
+	}
+}
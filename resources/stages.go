@@ -0,0 +1,29 @@
+// Package resources exposes the data files that both the game and the
+// headless tools need. Textures and sounds are embedded by the game itself.
+package resources
+
+import (
+	"brackeysGameJam/sim"
+	"embed"
+	"io/fs"
+	"os"
+)
+
+// stages holds the campaign, one JSON file per stage.
+//
+//go:embed stages/*.json
+var stages embed.FS
+
+// LoadStages loads the embedded campaign. If overrideDir is not empty, its
+// stage files replace the embedded ones of the same name and new names add
+// stages.
+func LoadStages(overrideDir string) ([]sim.StageDef, error) {
+	embedded, err := fs.Sub(stages, "stages")
+	if err != nil {
+		return nil, err
+	}
+	if overrideDir == "" {
+		return sim.LoadStages(embedded)
+	}
+	return sim.LoadStages(embedded, os.DirFS(overrideDir))
+}
@@ -0,0 +1,695 @@
+package main
+
+import (
+	"brackeysGameJam/settings"
+	"brackeysGameJam/sim"
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"strconv"
+	"time"
+)
+
+type titleScene struct {
+	button Button
+	menu   Menu
+}
+
+func (s *titleScene) Enter(g *Game) {
+	s.button = newButton(g.assets.button, g.layout, rl.White)
+	s.menu = Menu{
+		position: g.layout.Point(Center, -110, 50),
+		fontSize: 40,
+	}
+	// a replay is played with the difficulty and mode it was recorded with
+	if g.playback == nil {
+		s.menu.items = []menuItem{
+			{difficultyLabel(g.difficulty), func() {
+				g.difficulty = nextDifficulty(g.difficulty)
+			}},
+			{modeLabel(g.mode), func() {
+				g.mode = nextMode(g.mode)
+			}},
+		}
+	}
+	s.menu.items = append(s.menu.items, menuItem{"Settings", func() {
+		g.ChangeScene(&settingsScene{back: s})
+	}})
+}
+
+func (s *titleScene) Update(g *Game) {
+	if rl.IsKeyPressed(rl.KeyEscape) {
+		g.Quit()
+		return
+	}
+	if s.button.CheckInput(g.mouse()) {
+		g.startRun()
+		g.ChangeScene(&countdownScene{stageIdx: 0})
+		return
+	}
+	s.menu.Update(g.mouse())
+	if g.playback == nil {
+		s.menu.items[0].label = difficultyLabel(g.difficulty)
+		s.menu.items[1].label = modeLabel(g.mode)
+	}
+}
+
+func (s *titleScene) Draw(g *Game) {
+	drawStartBackground(g)
+	s.button.Draw()
+	s.menu.Draw()
+	g.layout.Text("The Cold Killer", Center, 0, -400, Top, 80, rl.Black)
+}
+
+func (s *titleScene) Exit(g *Game) {
+}
+
+func difficultyLabel(d sim.Difficulty) string {
+	return fmt.Sprintf("Difficulty: %s", d.Name)
+}
+
+func nextDifficulty(d sim.Difficulty) sim.Difficulty {
+	for i, candidate := range sim.Difficulties {
+		if candidate.Name == d.Name {
+			return sim.Difficulties[(i+1)%len(sim.Difficulties)]
+		}
+	}
+	return sim.Difficulties[0]
+}
+
+func modeLabel(m sim.Mode) string {
+	if m == sim.Endless {
+		return "Mode: Endless"
+	}
+	return "Mode: Campaign"
+}
+
+func nextMode(m sim.Mode) sim.Mode {
+	for i, candidate := range sim.Modes {
+		if candidate == m {
+			return sim.Modes[(i+1)%len(sim.Modes)]
+		}
+	}
+	return sim.Modes[0]
+}
+
+// countdownScene shows the stage number for a second before the stage
+// starts. The run time does not advance meanwhile.
+type countdownScene struct {
+	stageIdx int
+	// restarted is set when the simulation has already started the stage
+	// over by itself.
+	restarted  bool
+	beginTimer Timer
+}
+
+func (s *countdownScene) Enter(g *Game) {
+	if !rl.IsSoundPlaying(g.assets.bgm) {
+		rl.PlaySound(g.assets.bgm)
+	}
+	s.beginTimer.Init()
+	rl.PlaySound(g.assets.countdownSound)
+}
+
+func (s *countdownScene) Update(g *Game) {
+	secondsLeft := 1 - time.Since(s.beginTimer.gameInitTime).Seconds()
+	if secondsLeft <= 0 {
+		if !s.restarted {
+			g.world.StartStage(s.stageIdx)
+		}
+		g.ChangeScene(&playingScene{})
+	}
+}
+
+func (s *countdownScene) Draw(g *Game) {
+	text := fmt.Sprintf(
+		"%s / %s",
+		strconv.Itoa(s.stageIdx+1),
+		strconv.Itoa(g.world.StageCount()),
+	)
+	if g.world.Mode() == sim.Endless {
+		text = "endless"
+	}
+	g.layout.Text(text, Center, 0, 0, Center, 100, rl.Black)
+}
+
+func (s *countdownScene) Exit(g *Game) {
+}
+
+// playingScene steps the simulation on a fixed timestep and draws it.
+type playingScene struct {
+	accumulator time.Duration
+	// phaseChanged is when a boss last moved on to its next phase.
+	phaseChanged Timer
+	// shaken is when the screen last started shaking, and shakeStrength
+	// how far it moves at first.
+	shaken        Timer
+	shakeStrength float32
+}
+
+// bossFlash is how long the boss health bar lights up after a phase
+// change.
+const bossFlash = time.Duration(500) * time.Millisecond
+
+// screenShake is how long the screen shakes after a hit or a boss defeat.
+const screenShake = time.Duration(400) * time.Millisecond
+
+// shake starts shaking the screen, unless the player turned it off.
+func (s *playingScene) shake(g *Game, strength float32) {
+	if !g.settings.ScreenShake {
+		return
+	}
+	s.shaken.Init()
+	s.shakeStrength = strength
+}
+
+// shakeOffset is how far the world is drawn off its place right now. The
+// shake dies down over screenShake.
+func (s *playingScene) shakeOffset() rl.Vector2 {
+	elapsed := time.Since(s.shaken.gameInitTime)
+	if s.shakeStrength == 0 || elapsed >= screenShake {
+		return rl.Vector2{}
+	}
+	amount := s.shakeStrength * (1 - float32(elapsed)/float32(screenShake))
+	t := rl.GetTime() * 50
+	return rl.Vector2{
+		X: float32(math.Sin(t)) * amount,
+		Y: float32(math.Cos(t*1.3)) * amount,
+	}
+}
+
+func (s *playingScene) Enter(g *Game) {
+}
+
+func (s *playingScene) Update(g *Game) {
+	if rl.IsKeyPressed(rl.KeyEscape) || g.controls.pressed(Pause) {
+		g.ChangeScene(&pausedScene{playing: s})
+		return
+	}
+	if rl.IsKeyPressed(rl.KeyF3) {
+		g.debug = !g.debug
+	}
+
+	frameTime := time.Duration(float64(time.Second) * float64(rl.GetFrameTime()) * timeScale)
+	if frameTime > maxFrameTime {
+		frameTime = maxFrameTime
+	}
+	s.accumulator += frameTime
+	g.input.Poll()
+
+	for s.accumulator >= sim.FixedStep {
+		s.accumulator -= sim.FixedStep
+		in, ok := g.input.Next()
+		if !ok {
+			// the replay ended before the run did
+			g.Quit()
+			return
+		}
+		if g.recording != nil {
+			g.recording.Record(in)
+		}
+		events := g.world.Update(in, sim.FixedStep)
+		if events.StageRestarted {
+			g.ChangeScene(&countdownScene{stageIdx: g.world.StageIdx(), restarted: true})
+			return
+		}
+		if events.StageCleared {
+			newBest := g.recordStageCleared()
+			if g.world.IsFinalStage() {
+				g.endRun()
+				rl.PlaySound(g.assets.winSound)
+				rl.StopSound(g.assets.bgm)
+				g.ChangeScene(&victoryScene{newBest: newBest})
+			} else {
+				g.ChangeScene(&countdownScene{stageIdx: g.world.StageIdx() + 1})
+			}
+			return
+		}
+
+		if events.PlayerDied {
+			newBest := g.recordDeath()
+			g.endRun()
+			rl.PlaySound(g.assets.loseSound)
+			rl.StopSound(g.assets.bgm)
+			g.ChangeScene(&gameOverScene{newBest: newBest})
+			return
+		}
+
+		if events.ShotFired {
+			rl.PlaySound(g.assets.gunShot)
+		}
+		if events.PlayerHit {
+			s.shake(g, 12)
+		}
+		if events.BossPhase {
+			s.phaseChanged.Init()
+		}
+		if events.BossDefeated {
+			s.shake(g, 24)
+			// the final boss's win sound plays on the victory screen
+			if !g.world.IsFinalStage() {
+				rl.PlaySound(g.assets.winSound)
+			}
+		}
+	}
+}
+
+func (s *playingScene) Draw(g *Game) {
+	alpha := float32(s.accumulator) / float32(sim.FixedStep)
+	rl.BeginMode2D(rl.Camera2D{Offset: s.shakeOffset(), Zoom: 1})
+	rl.DrawTexture(
+		g.assets.backgrounds[g.world.Stage().Background],
+		0,
+		0,
+		rl.Color{
+			R: 150,
+			G: 150,
+			B: 150,
+			A: 255,
+		},
+	)
+	DrawDeadObjects(g.world, g.assets.sprites)
+	DrawObstacles(g.world)
+	DrawGameObjects(g.world, g.assets.sprites, alpha)
+	if g.debug {
+		DrawCollisionShapes(g.world, alpha)
+	}
+	rl.EndMode2D()
+	printYourTime(g.world.Elapsed(), false, g.layout)
+	if left, limited := g.world.TimeLeft(); limited {
+		printTimeLeft(left, g.layout)
+	}
+	if difficulty := g.world.Difficulty(); difficulty.MaxHP > 0 {
+		printLives(difficulty.Lives(g.world.Player().HP()), g.layout)
+	}
+	printWeapon(g.world.Player(), g.layout)
+	if boss := g.world.Boss(); boss != nil {
+		drawBossBar(boss, time.Since(s.phaseChanged.gameInitTime) < bossFlash, g.layout)
+	}
+	if g.world.Mode() == sim.Endless {
+		printScore(g.world.Score(), g.world.Kills(), false, g.layout)
+	}
+}
+
+func (s *playingScene) Exit(g *Game) {
+}
+
+// pausedScene freezes the stage being played. The simulation does not step,
+// so enemy plans, bullets and the run time all stand still.
+type pausedScene struct {
+	playing *playingScene
+	menu    Menu
+}
+
+func (s *pausedScene) Enter(g *Game) {
+	items := []menuItem{
+		{"Resume", func() {
+			g.ChangeScene(s.playing)
+		}},
+	}
+	// a replay cannot take new decisions, so it only offers to watch on
+	if live, ok := g.input.(*liveInput); ok {
+		items = append(items,
+			menuItem{"Restart Stage", func() {
+				live.restartStage()
+				g.ChangeScene(s.playing)
+			}},
+			menuItem{"Restart Run", func() {
+				g.abandonRun()
+				g.startRun()
+				g.ChangeScene(&countdownScene{stageIdx: 0})
+			}},
+		)
+	}
+	items = append(items,
+		menuItem{"Settings", func() {
+			g.ChangeScene(&settingsScene{back: s})
+		}},
+		menuItem{"Quit to Title", func() {
+			g.abandonRun()
+			rl.StopSound(g.assets.bgm)
+			g.ChangeScene(&titleScene{})
+		}},
+	)
+	s.menu = Menu{
+		items:    items,
+		position: g.layout.Point(Center, -150, -200),
+		fontSize: 60,
+	}
+}
+
+func (s *pausedScene) Update(g *Game) {
+	if rl.IsKeyPressed(rl.KeyEscape) || g.controls.pressed(Pause) {
+		g.ChangeScene(s.playing)
+		return
+	}
+	s.menu.Update(g.mouse())
+}
+
+func (s *pausedScene) Draw(g *Game) {
+	s.playing.Draw(g)
+	rl.DrawRectangleV(rl.Vector2{}, rl.Vector2{X: g.layout.Width, Y: g.layout.Height}, rl.Fade(rl.White, 0.5))
+	g.layout.Text("paused", Center, 0, -350, Top, 100, rl.Black)
+	s.menu.Draw()
+}
+
+func (s *pausedScene) Exit(g *Game) {
+}
+
+type gameOverScene struct {
+	button Button
+	// newBest is set when the endless score beat the record.
+	newBest bool
+	records []string
+}
+
+func (s *gameOverScene) Enter(g *Game) {
+	s.button = newButton(g.assets.button, g.layout, rl.Red)
+	s.records = gameOverRecords(g, s.newBest)
+}
+
+func (s *gameOverScene) Update(g *Game) {
+	if s.button.CheckInput(g.mouse()) {
+		if g.playback != nil {
+			g.Quit()
+			return
+		}
+		// restart game
+		g.startRun()
+		g.ChangeScene(&countdownScene{stageIdx: 0})
+	}
+}
+
+func (s *gameOverScene) Draw(g *Game) {
+	drawStartBackground(g)
+	g.layout.Text("you died.", Center, 0, -400, Top, 100, rl.Red)
+	if g.world.Mode() == sim.Endless {
+		printScore(g.world.Score(), g.world.Kills(), true, g.layout)
+	}
+	printRecords(s.records, g.layout)
+	printSeed(g.world.Seed(), g.layout)
+	s.button.Draw()
+}
+
+func (s *gameOverScene) Exit(g *Game) {
+}
+
+type victoryScene struct {
+	button Button
+	// newBest is set when the run beat the record.
+	newBest bool
+	records []string
+}
+
+func (s *victoryScene) Enter(g *Game) {
+	s.button = newButton(g.assets.button, g.layout, rl.Purple)
+	s.records = victoryRecords(g, s.newBest)
+}
+
+func (s *victoryScene) Update(g *Game) {
+	if s.button.CheckInput(g.mouse()) {
+		g.Quit()
+	}
+}
+
+func (s *victoryScene) Draw(g *Game) {
+	drawStartBackground(g)
+	g.layout.Text("You've Won!", Center, 0, -330, Top, 100, rl.White)
+	printYourTime(g.world.Elapsed(), true, g.layout)
+	printRecords(s.records, g.layout)
+	printSeed(g.world.Seed(), g.layout)
+	s.button.Draw()
+}
+
+func (s *victoryScene) Exit(g *Game) {
+}
+
+// settingsScene changes options and then goes back to the scene it was
+// opened from. The options are saved on the way out.
+type settingsScene struct {
+	back Scene
+	menu Menu
+	// labels make the label of every menu item from the current options.
+	labels []func() string
+}
+
+// resolutions are the window sizes the settings offer. 0 by 0 is the
+// monitor's own.
+var resolutions = [][2]int{{0, 0}, {1280, 720}, {1600, 900}, {1920, 1080}, {2560, 1440}}
+
+// fpsCaps are the frame rate caps the settings offer. 0 does not cap it.
+var fpsCaps = []int{30, 60, 120, 144, 240, 0}
+
+func (s *settingsScene) Enter(g *Game) {
+	o := g.settings
+	s.labels = nil
+	var items []menuItem
+	add := func(label func() string, action func()) {
+		s.labels = append(s.labels, label)
+		items = append(items, menuItem{label(), func() {
+			action()
+			g.applySettings()
+		}})
+	}
+	// a * marks what only takes effect after a restart
+	add(func() string { return fmt.Sprintf("Window: %s*", o.Window) }, func() {
+		o.Window = settings.WindowModes[(indexOf(settings.WindowModes, o.Window)+1)%len(settings.WindowModes)]
+	})
+	add(func() string { return "Resolution: " + resolutionLabel(o.Width, o.Height) + "*" }, func() {
+		next := resolutions[(indexOf(resolutions, [2]int{o.Width, o.Height})+1)%len(resolutions)]
+		o.Width, o.Height = next[0], next[1]
+	})
+	add(func() string { return "FPS cap: " + fpsLabel(o.FPS) }, func() {
+		o.FPS = fpsCaps[(indexOf(fpsCaps, o.FPS)+1)%len(fpsCaps)]
+	})
+	add(func() string { return "VSync: " + onOff(o.VSync) + "*" }, func() {
+		o.VSync = !o.VSync
+	})
+	add(func() string { return volumeLabel("Volume", o.MasterVolume) }, func() {
+		o.MasterVolume = nextVolume(o.MasterVolume)
+	})
+	add(func() string { return volumeLabel("Music", o.MusicVolume) }, func() {
+		o.MusicVolume = nextVolume(o.MusicVolume)
+	})
+	add(func() string { return volumeLabel("Sound effects", o.SFXVolume) }, func() {
+		o.SFXVolume = nextVolume(o.SFXVolume)
+	})
+	add(func() string { return "Screen shake: " + onOff(o.ScreenShake) }, func() {
+		o.ScreenShake = !o.ScreenShake
+	})
+	items = append(items,
+		menuItem{"Controls", func() {
+			g.ChangeScene(&controlsScene{back: s})
+		}},
+		menuItem{"Back", func() {
+			g.ChangeScene(s.back)
+		}},
+	)
+	s.menu = Menu{
+		items:    items,
+		position: g.layout.Point(Center, -150, -250),
+		fontSize: 40,
+	}
+}
+
+func (s *settingsScene) Update(g *Game) {
+	if rl.IsKeyPressed(rl.KeyEscape) {
+		g.ChangeScene(s.back)
+		return
+	}
+	s.menu.Update(g.mouse())
+	for i, label := range s.labels {
+		s.menu.items[i].label = label()
+	}
+}
+
+func (s *settingsScene) Draw(g *Game) {
+	drawStartBackground(g)
+	g.layout.Text("settings", Center, 0, -400, Top, 100, rl.Black)
+	s.menu.Draw()
+	g.layout.Text("* takes effect after a restart", Center, 0, 380, Top, 30, rl.Black)
+}
+
+func (s *settingsScene) Exit(g *Game) {
+	g.saveSettings()
+}
+
+func indexOf[T comparable](list []T, v T) int {
+	for i, candidate := range list {
+		if candidate == v {
+			return i
+		}
+	}
+	return -1
+}
+
+func resolutionLabel(width, height int) string {
+	if width == 0 {
+		return "native"
+	}
+	return fmt.Sprintf("%dx%d", width, height)
+}
+
+func fpsLabel(fps int) string {
+	if fps == 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(fps)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+func volumeLabel(name string, volume float32) string {
+	return fmt.Sprintf("%s: %.0f%%", name, volume*100)
+}
+
+// nextVolume steps through 0%, 10%, ... 100% and wraps around.
+func nextVolume(volume float32) float32 {
+	return float32(int(volume*10+1.5)%11) / 10
+}
+
+// controlSlots are the rows of the controls screen: an action and, for an
+// axis, the direction.
+var controlSlots = []struct {
+	label, action, sign string
+}{
+	{"Move up", "moveY", "-"},
+	{"Move left", "moveX", "-"},
+	{"Move down", "moveY", "+"},
+	{"Move right", "moveX", "+"},
+	{"Fire", "fire", ""},
+	{"Dash", "dash", ""},
+	{"Reload", "reload", ""},
+	{"Pause", "pause", ""},
+}
+
+// controlsScene rebinds the keys and mouse buttons of the player actions.
+// Picking an action waits for the next key or mouse button, and escape
+// gives up waiting. Gamepad bindings are only in the settings file.
+type controlsScene struct {
+	back Scene
+	menu Menu
+	// waiting is the index in controlSlots of the slot waiting for a key,
+	// -1 for none.
+	waiting int
+}
+
+func (s *controlsScene) Enter(g *Game) {
+	s.waiting = -1
+	var items []menuItem
+	for i := range controlSlots {
+		items = append(items, menuItem{"", func() {
+			s.waiting = i
+		}})
+	}
+	items = append(items,
+		menuItem{"", func() {
+			// steps through 5%, 10%, ... 50% and wraps around
+			g.settings.Deadzone = float32(int(g.settings.Deadzone*20+0.5)%10+1) / 20
+			g.applySettings()
+		}},
+		menuItem{"Reset to Defaults", func() {
+			defaults := settings.Defaults()
+			g.settings.Bindings = defaults.Bindings
+			g.settings.Deadzone = defaults.Deadzone
+			g.applySettings()
+		}},
+		menuItem{"Back", func() {
+			g.ChangeScene(s.back)
+		}},
+	)
+	s.menu = Menu{
+		items:    items,
+		position: g.layout.Point(Center, -150, -250),
+		fontSize: 40,
+	}
+	s.updateLabels(g)
+}
+
+func (s *controlsScene) Update(g *Game) {
+	if s.waiting >= 0 {
+		if input := s.nextInput(); input != "" {
+			slot := controlSlots[s.waiting]
+			if input != "Escape" {
+				g.settings.Bind(slot.action, slot.sign, input)
+				g.applySettings()
+			}
+			s.waiting = -1
+		}
+		s.updateLabels(g)
+		return
+	}
+	if rl.IsKeyPressed(rl.KeyEscape) {
+		g.ChangeScene(s.back)
+		return
+	}
+	s.menu.Update(g.mouse())
+	s.updateLabels(g)
+}
+
+// nextInput is the name of the key or mouse button pressed this frame, or
+// "Escape". Keys that cannot be bound are ignored.
+func (s *controlsScene) nextInput() string {
+	for key := rl.GetKeyPressed(); key != 0; key = rl.GetKeyPressed() {
+		if key == rl.KeyEscape {
+			return "Escape"
+		}
+		if name := inputName(keyboard, key); name != "" {
+			return name
+		}
+	}
+	// a button is taken on release, or the menu would see the release too
+	for _, button := range mouseButtonNames {
+		if rl.IsMouseButtonReleased(rl.MouseButton(button)) {
+			return inputName(mouse, button)
+		}
+	}
+	return ""
+}
+
+func (s *controlsScene) updateLabels(g *Game) {
+	for i, slot := range controlSlots {
+		input := g.settings.Binding(slot.action, slot.sign)
+		switch {
+		case i == s.waiting:
+			input = "press a key"
+		case input == "":
+			input = "none"
+		}
+		s.menu.items[i].label = fmt.Sprintf("%s: %s", slot.label, input)
+	}
+	s.menu.items[len(controlSlots)].label = fmt.Sprintf("Stick deadzone: %.0f%%", g.settings.Deadzone*100)
+}
+
+func (s *controlsScene) Draw(g *Game) {
+	drawStartBackground(g)
+	g.layout.Text("controls", Center, 0, -400, Top, 100, rl.Black)
+	s.menu.Draw()
+	g.layout.Text("gamepad buttons are bound in settings.json", Center, 0, 420, Top, 30, rl.Black)
+}
+
+func (s *controlsScene) Exit(g *Game) {
+	g.saveSettings()
+}
+
+// drawStartBackground covers the canvas with the start picture, cropping
+// whatever sticks out on a canvas of another shape.
+func drawStartBackground(g *Game) {
+	texture := g.assets.start
+	size := rl.Vector2{X: float32(texture.Width), Y: float32(texture.Height)}
+	scale := max(g.layout.Width/size.X, g.layout.Height/size.Y)
+	corner := g.layout.Place(Center, 0, 0, Center, size.X*scale, size.Y*scale)
+	rl.DrawTexturePro(
+		texture,
+		rl.Rectangle{Width: size.X, Height: size.Y},
+		rl.Rectangle{X: corner.X, Y: corner.Y, Width: size.X * scale, Height: size.Y * scale},
+		rl.Vector2{},
+		0,
+		rl.Gray,
+	)
+}
@@ -0,0 +1,149 @@
+// Package scores keeps the personal bests of the player in a small JSON file
+// in the user's config directory. It needs no window, so the records survive
+// whatever happens to the game.
+package scores
+
+import (
+	"brackeysGameJam/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// fileVersion is bumped whenever the file layout changes. A file of a newer
+// version is never overwritten, so an older build cannot lose its records.
+const fileVersion = 1
+
+// File is every record kept, one board per difficulty.
+type File struct {
+	Version int `json:"version"`
+	// Boards are keyed by the name of a sim.Difficulty.
+	Boards map[string]*Board `json:"boards"`
+}
+
+// Board is the records of one difficulty.
+type Board struct {
+	// BestRun is the fastest win of the campaign.
+	BestRun *Result `json:"bestRun,omitempty"`
+	// BestEndless is the highest endless mode score.
+	BestEndless *Result `json:"bestEndless,omitempty"`
+	// Stages are keyed by stage name.
+	Stages map[string]*Stage `json:"stages"`
+}
+
+// Stage is the records of one stage of the campaign.
+type Stage struct {
+	// Best is the fastest clear.
+	Best   *Result `json:"best,omitempty"`
+	Deaths int     `json:"deaths"`
+}
+
+// Result is one record and when and with which seed it was set.
+type Result struct {
+	Seconds float64 `json:"seconds"`
+	// Score is only set for endless mode.
+	Score int       `json:"score,omitempty"`
+	Date  time.Time `json:"date"`
+	Seed  int64     `json:"seed"`
+}
+
+// New returns an empty file.
+func New() *File {
+	return &File{Version: fileVersion, Boards: make(map[string]*Board)}
+}
+
+// Load reads the records at path. A missing file is not an error, there are
+// just no records yet.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f := New()
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Version < 1 || f.Version > fileVersion {
+		return nil, fmt.Errorf("%s: unsupported version %d, want at most %d", path, f.Version, fileVersion)
+	}
+	if f.Boards == nil {
+		f.Boards = make(map[string]*Board)
+	}
+	return f, nil
+}
+
+// Save writes f to path. It writes a temporary file next to it first and
+// renames it over the old one, so a crash halfway leaves the old records
+// intact.
+func Save(path string, f *File) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return config.WriteFile(path, data)
+}
+
+// Board returns the board of difficulty, creating it if needed.
+func (f *File) Board(difficulty string) *Board {
+	b, ok := f.Boards[difficulty]
+	if !ok {
+		b = &Board{}
+		f.Boards[difficulty] = b
+	}
+	if b.Stages == nil {
+		b.Stages = make(map[string]*Stage)
+	}
+	return b
+}
+
+// Stage returns the records of the stage called name, creating them if
+// needed.
+func (b *Board) Stage(name string) *Stage {
+	s, ok := b.Stages[name]
+	if !ok {
+		s = &Stage{}
+		b.Stages[name] = s
+	}
+	return s
+}
+
+// StageCleared records a clear of the stage called name in d and reports
+// whether it is a new best.
+func (b *Board) StageCleared(name string, d time.Duration, date time.Time, seed int64) bool {
+	s := b.Stage(name)
+	if s.Best != nil && s.Best.Seconds <= d.Seconds() {
+		return false
+	}
+	s.Best = &Result{Seconds: d.Seconds(), Date: date, Seed: seed}
+	return true
+}
+
+// Died records a death in the stage called name.
+func (b *Board) Died(name string) {
+	b.Stage(name).Deaths++
+}
+
+// RunWon records a campaign won in d and reports whether it is a new best.
+func (b *Board) RunWon(d time.Duration, date time.Time, seed int64) bool {
+	if b.BestRun != nil && b.BestRun.Seconds <= d.Seconds() {
+		return false
+	}
+	b.BestRun = &Result{Seconds: d.Seconds(), Date: date, Seed: seed}
+	return true
+}
+
+// EndlessOver records an endless run that scored score in d and reports
+// whether it is a new best.
+func (b *Board) EndlessOver(score int, d time.Duration, date time.Time, seed int64) bool {
+	if b.BestEndless != nil && b.BestEndless.Score >= score {
+		return false
+	}
+	b.BestEndless = &Result{Seconds: d.Seconds(), Score: score, Date: date, Seed: seed}
+	return true
+}
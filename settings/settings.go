@@ -0,0 +1,273 @@
+// Package settings keeps the player's options in a small JSON file in the
+// user's config directory. It needs no window, so the game can read it
+// before opening one.
+package settings
+
+import (
+	"brackeysGameJam/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// fileVersion is bumped whenever the file layout changes. Older versions
+// still load:
+//   - 2 replaced the key of every action with a list of bindings.
+const fileVersion = 2
+
+// WindowMode is how the game window is shown.
+type WindowMode string
+
+const (
+	// Fullscreen switches the monitor to the resolution of the game.
+	Fullscreen WindowMode = "fullscreen"
+	// Borderless covers the monitor with a window at its own resolution.
+	Borderless WindowMode = "borderless"
+	// Windowed opens a normal window of the resolution of the game.
+	Windowed WindowMode = "windowed"
+)
+
+// WindowModes lists the ways the window can be shown.
+var WindowModes = []WindowMode{Fullscreen, Borderless, Windowed}
+
+// Actions are the player actions that inputs can be bound to. The first
+// four are axes between -1 and 1, the others are buttons.
+//
+// An input is bound by its name: a key like "W" or "Space", a mouse button
+// like "MouseLeft", or a gamepad button or axis, whose names all start with
+// "Pad", like "PadA" or "PadLeftX". A key or button bound to an axis has a
+// "-" or "+" in front for the direction it pushes.
+var Actions = []string{"moveX", "moveY", "aimX", "aimY", "fire", "dash", "reload", "pause"}
+
+// IsAxis reports whether action is one of the axes of Actions.
+func IsAxis(action string) bool {
+	for _, axis := range Actions[:4] {
+		if axis == action {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPad reports whether binding is a gamepad input.
+func IsPad(binding string) bool {
+	return strings.HasPrefix(strings.TrimLeft(binding, "-+"), "Pad")
+}
+
+// Split splits binding into its direction, "-", "+" or "", and the name of
+// its input.
+func Split(binding string) (sign, input string) {
+	if strings.HasPrefix(binding, "-") || strings.HasPrefix(binding, "+") {
+		return binding[:1], binding[1:]
+	}
+	return "", binding
+}
+
+// File is every option. Width and Height of 0 use the monitor's resolution,
+// and an FPS of 0 does not cap the frame rate.
+type File struct {
+	Version int        `json:"version"`
+	Window  WindowMode `json:"window"`
+	Width   int        `json:"width"`
+	Height  int        `json:"height"`
+	FPS     int        `json:"fps"`
+	VSync   bool       `json:"vsync"`
+	// the volumes are between 0 and 1; music and sound effects are scaled
+	// by the master volume
+	MasterVolume float32 `json:"masterVolume"`
+	MusicVolume  float32 `json:"musicVolume"`
+	SFXVolume    float32 `json:"sfxVolume"`
+	ScreenShake  bool    `json:"screenShake"`
+	// Deadzone is how far, between 0 and 1, a stick must be pushed before
+	// it counts.
+	Deadzone float32 `json:"deadzone"`
+	// Bindings maps every action to the inputs bound to it.
+	Bindings map[string][]string `json:"bindings"`
+}
+
+// Defaults returns the options of a fresh install, which are how the game
+// always used to run.
+func Defaults() *File {
+	return &File{
+		Version:      fileVersion,
+		Window:       Fullscreen,
+		FPS:          60,
+		MasterVolume: 1,
+		MusicVolume:  1,
+		SFXVolume:    1,
+		ScreenShake:  true,
+		Deadzone:     0.2,
+		Bindings: map[string][]string{
+			"moveX":  {"-A", "+D", "PadLeftX"},
+			"moveY":  {"-W", "+S", "PadLeftY"},
+			"aimX":   {"PadRightX"},
+			"aimY":   {"PadRightY"},
+			"fire":   {"MouseLeft", "PadRT"},
+			"dash":   {"Space", "MouseRight", "PadLT"},
+			"reload": {"R", "PadX"},
+			"pause":  {"P", "PadStart"},
+		},
+	}
+}
+
+// keysV1 are where the keys of a version 1 file go: the action and the
+// direction.
+var keysV1 = map[string][2]string{
+	"up":     {"moveY", "-"},
+	"left":   {"moveX", "-"},
+	"down":   {"moveY", "+"},
+	"right":  {"moveX", "+"},
+	"reload": {"reload", ""},
+	"pause":  {"pause", ""},
+}
+
+// Load reads the options at path. A missing file gives the defaults, and
+// an option missing from the file keeps its default.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Defaults(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// bindings in the file are merged into the default ones
+	f := Defaults()
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Version < 1 || f.Version > fileVersion {
+		return nil, fmt.Errorf("%s: unsupported version %d, want at most %d", path, f.Version, fileVersion)
+	}
+	if f.Bindings == nil {
+		f.Bindings = Defaults().Bindings
+	}
+	if f.Version == 1 {
+		var v1 struct {
+			Keys map[string]string `json:"keys"`
+		}
+		if err := json.Unmarshal(data, &v1); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for name, key := range v1.Keys {
+			slot, ok := keysV1[name]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown action %q", path, name)
+			}
+			f.Bind(slot[0], slot[1], key)
+		}
+		f.Version = fileVersion
+	}
+	if err := f.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+func (f *File) validate() error {
+	known := false
+	for _, m := range WindowModes {
+		known = known || f.Window == m
+	}
+	if !known {
+		return fmt.Errorf("unknown window mode %q", f.Window)
+	}
+	if f.Width < 0 || f.Height < 0 || (f.Width == 0) != (f.Height == 0) {
+		return fmt.Errorf("resolution %dx%d, want both positive or both 0", f.Width, f.Height)
+	}
+	if f.FPS < 0 {
+		return fmt.Errorf("fps %d is negative", f.FPS)
+	}
+	for name, volume := range map[string]float32{
+		"masterVolume": f.MasterVolume,
+		"musicVolume":  f.MusicVolume,
+		"sfxVolume":    f.SFXVolume,
+	} {
+		if volume < 0 || volume > 1 {
+			return fmt.Errorf("%s %g, want between 0 and 1", name, volume)
+		}
+	}
+	if f.Deadzone < 0 || f.Deadzone >= 1 {
+		return fmt.Errorf("deadzone %g, want at least 0 and below 1", f.Deadzone)
+	}
+	for action, bindings := range f.Bindings {
+		if !isAction(action) {
+			return fmt.Errorf("unknown action %q", action)
+		}
+		for _, binding := range bindings {
+			sign, input := Split(binding)
+			switch {
+			case input == "":
+				return fmt.Errorf("%s: empty binding", action)
+			case IsAxis(action) && !IsPad(input) && sign == "":
+				return fmt.Errorf("%s: %q needs a direction, -%s or +%s", action, binding, input, input)
+			case (!IsAxis(action) || IsPad(input)) && sign != "":
+				return fmt.Errorf("%s: %q cannot have a direction", action, binding)
+			}
+		}
+	}
+	return nil
+}
+
+func isAction(name string) bool {
+	for _, action := range Actions {
+		if action == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Save writes f to path. It writes a temporary file next to it first and
+// renames it over the old one, so a crash halfway leaves the old options
+// intact.
+func Save(path string, f *File) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return config.WriteFile(path, data)
+}
+
+// Binding is the key or mouse button bound to action in the direction
+// sign, "" if there is none. It is the one Bind replaces.
+func (f *File) Binding(action, sign string) string {
+	for _, binding := range f.Bindings[action] {
+		if s, input := Split(binding); s == sign && !IsPad(input) {
+			return input
+		}
+	}
+	return ""
+}
+
+// Bind binds the key or mouse button input to action in the direction sign,
+// "" for a button, in place of the one it had. Whatever had input before
+// gets the old one instead, so nothing does two things. Gamepad bindings
+// are left alone.
+func (f *File) Bind(action, sign, input string) {
+	old := f.Binding(action, sign)
+	for other, bindings := range f.Bindings {
+		kept := bindings[:0]
+		for _, binding := range bindings {
+			s, in := Split(binding)
+			if in == input && !(other == action && s == sign) {
+				if old == "" {
+					continue
+				}
+				binding = s + old
+			}
+			kept = append(kept, binding)
+		}
+		f.Bindings[other] = kept
+	}
+	for i, binding := range f.Bindings[action] {
+		if s, in := Split(binding); s == sign && in == old && old != "" {
+			f.Bindings[action][i] = sign + input
+			return
+		}
+	}
+	f.Bindings[action] = append(f.Bindings[action], sign+input)
+}
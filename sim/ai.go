@@ -0,0 +1,225 @@
+package sim
+
+import "time"
+
+// Brain decides what an enemy does. Every step the first Rule whose
+// condition holds is the one in charge. While it stays in charge its current
+// step keeps running until its time is up, then the rule picks its next
+// step. When another rule takes charge, it starts over at once.
+type Brain struct {
+	Rules []Rule
+}
+
+// Rule is a condition and what to do while it holds.
+type Rule struct {
+	// When decides whether the rule applies, nil means always.
+	When Condition
+	// Steps are the behaviors the rule runs. By default one of them is
+	// picked at random each time the previous one runs out.
+	Steps []Step
+	// Sequence runs Steps in order instead.
+	Sequence bool
+	// Commit keeps the rule in charge until all its steps are done, even
+	// if a rule above it starts to apply or its own condition stops
+	// holding.
+	Commit bool
+}
+
+// Step is a behavior held for a random time between Min and Max. A Max of
+// zero holds it for as long as its rule stays in charge.
+type Step struct {
+	Behavior Behavior
+	Min      time.Duration
+	Max      time.Duration
+}
+
+// Hold makes a step that runs b for exactly d, or indefinitely if d is zero.
+func Hold(b Behavior, d time.Duration) Step {
+	return Step{Behavior: b, Min: d, Max: d}
+}
+
+func (s Step) duration(w *World) time.Duration {
+	if s.Max <= s.Min {
+		return s.Min
+	}
+	return s.Min + time.Duration(w.rng.Int63n(int64(s.Max-s.Min)))
+}
+
+// Speed is a range of speeds, in units per second, a behavior picks from
+// whenever it starts.
+type Speed struct {
+	Min float32
+	Max float32
+}
+
+// Fixed is a speed range with a single speed.
+func Fixed(speed float32) Speed {
+	return Speed{Min: speed, Max: speed}
+}
+
+// roll picks a speed, scaled up by the endless mode director.
+func (s Speed) roll(w *World) float32 {
+	if s.Max <= s.Min {
+		return s.Min * w.speedScale()
+	}
+	return (s.Min + w.rng.Float32()*(s.Max-s.Min)) * w.speedScale()
+}
+
+// mind is where an enemy is in its brain.
+type mind struct {
+	// rule and step index the running step, step is meaningless while
+	// behavior is nil.
+	rule     int
+	step     int
+	behavior *Behavior
+	started  time.Duration
+	duration time.Duration
+}
+
+func (m *mind) stepDone(w *World) bool {
+	return m.duration > 0 && w.clock-m.started >= m.duration
+}
+
+// think lets the brain pick what to do this step and does it.
+func (e *Enemy) think(w *World) {
+	m := &e.mind
+	rules := e.archetype.Brain.Rules
+	if m.behavior != nil && rules[m.rule].Commit {
+		rule := rules[m.rule]
+		if !m.stepDone(w) {
+			m.behavior.act(e, w)
+			return
+		}
+		if rule.Sequence && m.step+1 < len(rule.Steps) {
+			e.startStep(w, m.rule, m.step+1)
+			m.behavior.act(e, w)
+			return
+		}
+	}
+
+	for i, rule := range rules {
+		if rule.When != nil && !rule.When(e, w) {
+			continue
+		}
+		switch {
+		case m.behavior != nil && i == m.rule && !m.stepDone(w):
+			// the running step goes on
+		case m.behavior != nil && i == m.rule && rule.Sequence:
+			e.startStep(w, i, (m.step+1)%len(rule.Steps))
+		case rule.Sequence:
+			e.startStep(w, i, 0)
+		default:
+			e.startStep(w, i, w.rng.Intn(len(rule.Steps)))
+		}
+		m.behavior.act(e, w)
+		return
+	}
+	// no rule applies, stand still
+	m.behavior = nil
+	e.lastPlanVector = Vector2{}
+}
+
+func (e *Enemy) startStep(w *World, rule, step int) {
+	s := &e.archetype.Brain.Rules[rule].Steps[step]
+	e.mind = mind{
+		rule:     rule,
+		step:     step,
+		behavior: &s.Behavior,
+		started:  w.clock,
+		duration: s.duration(w),
+	}
+	if s.Behavior.start != nil {
+		s.Behavior.start(e, w)
+	}
+}
+
+// Condition is a question about an enemy and its surroundings.
+type Condition func(e *Enemy, w *World) bool
+
+// DistanceBelow holds while the enemy is closer than distance to the player.
+func DistanceBelow(distance float32) Condition {
+	return func(e *Enemy, w *World) bool {
+		return e.distanceTo(w.player.Shape().Centroid()) < distance
+	}
+}
+
+// DistanceAbove holds while the enemy is farther than distance from the
+// player.
+func DistanceAbove(distance float32) Condition {
+	return func(e *Enemy, w *World) bool {
+		return e.distanceTo(w.player.Shape().Centroid()) > distance
+	}
+}
+
+// LineOfSight holds while nothing stands between the enemy and the player.
+func LineOfSight() Condition {
+	return func(e *Enemy, w *World) bool {
+		return w.lineOfSight(e.Shape().Centroid(), w.player.Shape().Centroid())
+	}
+}
+
+// HealthBelow holds once the enemy is down to less than fraction of its HP.
+func HealthBelow(fraction float32) Condition {
+	return func(e *Enemy, w *World) bool {
+		return float32(e.hp) < fraction*float32(e.archetype.HP)
+	}
+}
+
+// InPhase holds once a boss has reached phase or a later one.
+func InPhase(phase int) Condition {
+	return func(e *Enemy, w *World) bool {
+		return e.Phase() >= phase
+	}
+}
+
+// TimeInStateAbove holds once the running step has gone on for longer than
+// d.
+func TimeInStateAbove(d time.Duration) Condition {
+	return func(e *Enemy, w *World) bool {
+		return e.mind.behavior != nil && w.clock-e.mind.started > d
+	}
+}
+
+// Doing holds while the running behavior is called name.
+func Doing(name string) Condition {
+	return func(e *Enemy, w *World) bool {
+		return e.mind.behavior != nil && e.mind.behavior.Name == name
+	}
+}
+
+// OutOfArena holds while the enemy is entirely outside the arena.
+func OutOfArena() Condition {
+	return func(e *Enemy, w *World) bool {
+		return e.isOutOfArena(w)
+	}
+}
+
+func Not(c Condition) Condition {
+	return func(e *Enemy, w *World) bool {
+		return !c(e, w)
+	}
+}
+
+// All holds when every condition holds.
+func All(conditions ...Condition) Condition {
+	return func(e *Enemy, w *World) bool {
+		for _, c := range conditions {
+			if !c(e, w) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any holds when at least one condition holds.
+func Any(conditions ...Condition) Condition {
+	return func(e *Enemy, w *World) bool {
+		for _, c := range conditions {
+			if c(e, w) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,306 @@
+package sim
+
+import "time"
+
+// Color is the RGBA tint the front end draws a sprite with.
+type Color struct {
+	R, G, B, A uint8
+}
+
+// Archetype is a kind of enemy: how it looks, how much it takes and how it
+// moves.
+type Archetype struct {
+	Name string
+	Tint Color
+	// Size is the width and height of the sprite.
+	Size float32
+	HP   int
+	// KnockbackScale multiplies the push of bullets, armored enemies barely
+	// move.
+	KnockbackScale float32
+	// Pack is how many enemies spawn side by side for each one a stage asks
+	// for.
+	Pack int
+	// SplitInto is what the enemy breaks into on death, SplitCount times.
+	SplitInto  *Archetype
+	SplitCount int
+	Brain      Brain
+	Flocking   Flocking
+	// Boss enemies get a health bar and go down in a defeat sequence that
+	// takes everything else with them.
+	Boss bool
+	// Phases are the fractions of HP below which a boss moves on to its
+	// next phase, highest first.
+	Phases []float32
+}
+
+const (
+	ghostWindUp    = time.Duration(600) * time.Millisecond
+	ghostOvershoot = 500
+)
+
+// ghostBrain is the original enemy's behavior. It picks a plan at random
+// whenever the last one runs out and keeps the heading it chases or rushes
+// along until then. When it leaves the arena it winds up and dashes back
+// through the player.
+var ghostBrain = Brain{Rules: []Rule{
+	TelegraphedRush(OutOfArena(), ghostWindUp, Speed{Min: 1800, Max: 2040}, ghostOvershoot, 0),
+	{
+		Steps: []Step{
+			Hold(Idle(), time.Duration(50)*time.Millisecond),
+			Hold(Locked(Chase(Speed{Min: 300, Max: 1140})), time.Duration(50)*time.Millisecond),
+			Hold(Wander(Speed{Min: 300, Max: 1140}), time.Duration(500)*time.Millisecond),
+			Hold(Locked(Rush(Speed{Min: 600, Max: 1440})), time.Duration(500)*time.Millisecond),
+		},
+	},
+}}
+
+const (
+	shooterSpeed        = 400
+	shooterRange        = 600
+	shooterRangeSlack   = 150
+	shooterShotSpeed    = 900
+	shooterFireInterval = time.Duration(1600) * time.Millisecond
+)
+
+const (
+	chargerSpeed      = 350
+	chargerDashSpeed  = 2400
+	chargerReach      = 900
+	chargerPatience   = time.Duration(2000) * time.Millisecond
+	chargerWindUpTime = time.Duration(700) * time.Millisecond
+	chargerOvershoot  = 300
+	chargerRecover    = time.Duration(600) * time.Millisecond
+)
+
+var (
+	// Ghost is the enemy of the original game, which wanders and rushes at
+	// random.
+	Ghost = Archetype{
+		Name:           "enemy",
+		Tint:           Color{R: 255, G: 255, B: 255, A: 255},
+		Size:           100,
+		HP:             3,
+		KnockbackScale: 1,
+		Pack:           1,
+		Brain:          ghostBrain,
+		Flocking:       Flocking{Radius: 150, Separation: 1, Alignment: 0.1, Cohesion: 0.05, Avoidance: 1},
+	}
+	// Shooter keeps its distance and lobs slow shots at the player.
+	Shooter = Archetype{
+		Name:           "shooter",
+		Tint:           Color{R: 170, G: 120, B: 255, A: 255},
+		Size:           90,
+		HP:             2,
+		KnockbackScale: 1,
+		Pack:           1,
+		Brain: Brain{Rules: []Rule{
+			{When: OutOfArena(), Steps: []Step{Hold(Chase(Fixed(shooterSpeed)), 0)}},
+			{
+				When:  DistanceAbove(shooterRange + shooterRangeSlack),
+				Steps: []Step{Hold(Shooting(Chase(Fixed(shooterSpeed)), shooterShotSpeed, shooterFireInterval), 0)},
+			},
+			{
+				When:  DistanceBelow(shooterRange - shooterRangeSlack),
+				Steps: []Step{Hold(Shooting(Flee(Fixed(shooterSpeed)), shooterShotSpeed, shooterFireInterval), 0)},
+			},
+			{Steps: []Step{Hold(Shooting(Strafe(Fixed(shooterSpeed/2)), shooterShotSpeed, shooterFireInterval), 0)}},
+		}},
+		Flocking: Flocking{Radius: 200, Separation: 1.2, Avoidance: 1},
+	}
+	// Splitter breaks into two splitlings when it dies.
+	Splitter = Archetype{
+		Name:           "splitter",
+		Tint:           Color{R: 120, G: 255, B: 140, A: 255},
+		Size:           110,
+		HP:             4,
+		KnockbackScale: 0.8,
+		Pack:           1,
+		SplitInto:      &Splitling,
+		SplitCount:     2,
+		Brain:          ghostBrain,
+		Flocking:       Flocking{Radius: 160, Separation: 1, Alignment: 0.1, Cohesion: 0.05, Avoidance: 1},
+	}
+	Splitling = Archetype{
+		Name:           "splitling",
+		Tint:           Color{R: 180, G: 255, B: 190, A: 255},
+		Size:           60,
+		HP:             1,
+		KnockbackScale: 1.5,
+		Pack:           1,
+		Brain:          Brain{Rules: []Rule{{Steps: []Step{Hold(Chase(Fixed(700)), 0)}}}},
+		Flocking:       Flocking{Radius: 100, Separation: 0.8, Alignment: 0.3, Cohesion: 0.2, Avoidance: 1},
+	}
+	// Tank is slow and armored, and loses its temper when badly hurt.
+	Tank = Archetype{
+		Name:           "tank",
+		Tint:           Color{R: 140, G: 150, B: 170, A: 255},
+		Size:           140,
+		HP:             15,
+		KnockbackScale: 0.15,
+		Pack:           1,
+		Brain: Brain{Rules: []Rule{
+			{When: HealthBelow(0.34), Steps: []Step{Hold(Rush(Fixed(450)), 0)}},
+			{Steps: []Step{Hold(Chase(Fixed(200)), 0)}},
+		}},
+		Flocking: Flocking{Radius: 200, Separation: 0.5, Avoidance: 0.5},
+	}
+	// Charger winds up, showing where it will go, then dashes in a straight
+	// line.
+	Charger = Archetype{
+		Name:           "charger",
+		Tint:           Color{R: 255, G: 180, B: 80, A: 255},
+		Size:           100,
+		HP:             4,
+		KnockbackScale: 0.5,
+		Pack:           1,
+		Brain: Brain{Rules: []Rule{
+			{When: OutOfArena(), Steps: []Step{Hold(Chase(Fixed(chargerSpeed)), 0)}},
+			TelegraphedRush(
+				Any(DistanceBelow(chargerReach), TimeInStateAbove(chargerPatience)),
+				chargerWindUpTime, Fixed(chargerDashSpeed), chargerOvershoot, chargerRecover,
+			),
+			{Steps: []Step{Hold(Chase(Fixed(chargerSpeed)), 0)}},
+		}},
+		Flocking: Flocking{Radius: 150, Separation: 1, Avoidance: 1},
+	}
+	// Swarmer is small and weak but comes in packs. A pack rings the
+	// player, then collapses on it.
+	Swarmer = Archetype{
+		Name:           "swarmer",
+		Tint:           Color{R: 120, G: 220, B: 255, A: 255},
+		Size:           55,
+		HP:             1,
+		KnockbackScale: 1.5,
+		Pack:           5,
+		Brain: Brain{Rules: []Rule{
+			{When: OutOfArena(), Steps: []Step{Hold(Chase(Fixed(650)), 0)}},
+			{
+				When:   All(Doing("Surround"), TimeInStateAbove(time.Duration(1200)*time.Millisecond)),
+				Commit: true,
+				Steps:  []Step{Hold(Rush(Fixed(900)), time.Duration(800)*time.Millisecond)},
+			},
+			{Steps: []Step{Hold(Surround(Fixed(650), 300), 0)}},
+		}},
+		Flocking: Flocking{Radius: 110, Separation: 1.2, Alignment: 0.4, Cohesion: 0.3, Avoidance: 1},
+	}
+)
+
+const (
+	bossWindUp         = time.Duration(900) * time.Millisecond
+	bossRushSpeed      = 2600
+	bossRushRecover    = time.Duration(700) * time.Millisecond
+	bossShotSpeed      = 700
+	bossStrafeSpeed    = 350
+	bossStrafeTime     = time.Duration(2500) * time.Millisecond
+	bossChaseSpeed     = 250
+	bossChaseTime      = time.Duration(2000) * time.Millisecond
+	bossBurstPause     = time.Duration(600) * time.Millisecond
+	bossSummonPause    = time.Duration(1000) * time.Millisecond
+	arenaRushOvershoot = 2000
+)
+
+// arenaRush is a wind-up and a dash that carries the boss right across the
+// arena, then a breather.
+func arenaRush(speed float32) []Step {
+	return []Step{
+		Hold(WindUp(), bossWindUp),
+		Hold(Dash(Fixed(speed), arenaRushOvershoot), 0),
+		Hold(Idle(), bossRushRecover),
+	}
+}
+
+// bossBrain runs through three phases, switching as soon as the health
+// falls below a threshold. The boss bursts and chases first, calls adds and
+// rushes across the arena from the second phase, and does it all faster
+// with denser bursts in the last. add is what it summons.
+func bossBrain(add *Archetype, burst int) Brain {
+	phase1 := []Step{
+		Hold(Chase(Fixed(bossChaseSpeed)), bossChaseTime),
+		Hold(Burst(burst, bossShotSpeed), bossBurstPause),
+		Hold(Chase(Fixed(bossChaseSpeed)), bossChaseTime),
+		Hold(Burst(burst, bossShotSpeed), bossBurstPause),
+	}
+	phase2 := []Step{
+		Hold(Summon(add, 3), bossSummonPause),
+		Hold(Shooting(Strafe(Fixed(bossStrafeSpeed)), bossShotSpeed, shooterFireInterval/2), bossStrafeTime),
+		Hold(Burst(burst+4, bossShotSpeed), bossBurstPause),
+	}
+	phase2 = append(phase2, arenaRush(bossRushSpeed)...)
+	phase3 := []Step{
+		Hold(Burst(burst+8, bossShotSpeed*1.2), bossBurstPause/2),
+		Hold(Summon(add, 4), bossSummonPause/2),
+	}
+	phase3 = append(phase3, arenaRush(bossRushSpeed*1.2)...)
+	phase3 = append(phase3,
+		Hold(Burst(burst+8, bossShotSpeed*1.2), bossBurstPause/2),
+		Hold(Burst(burst+8, bossShotSpeed*1.2), bossBurstPause/2),
+	)
+	phase3 = append(phase3, arenaRush(bossRushSpeed*1.2)...)
+	return Brain{Rules: []Rule{
+		{When: OutOfArena(), Steps: []Step{Hold(Chase(Fixed(bossStrafeSpeed*2)), 0)}},
+		{When: InPhase(3), Steps: phase3, Sequence: true},
+		{When: InPhase(2), Steps: phase2, Sequence: true},
+		{Steps: phase1, Sequence: true},
+	}}
+}
+
+var (
+	// Warden guards every fifth stage.
+	Warden = Archetype{
+		Name:           "warden",
+		Tint:           Color{R: 150, G: 200, B: 255, A: 255},
+		Size:           240,
+		HP:             60,
+		KnockbackScale: 0.05,
+		Pack:           1,
+		Brain:          bossBrain(&Ghost, 12),
+		Flocking:       Flocking{Radius: 300, Avoidance: 1},
+		Boss:           true,
+		Phases:         []float32{0.66, 0.33},
+	}
+	// ColdKiller waits at the end of the campaign.
+	ColdKiller = Archetype{
+		Name:           "coldkiller",
+		Tint:           Color{R: 255, G: 90, B: 120, A: 255},
+		Size:           280,
+		HP:             100,
+		KnockbackScale: 0.03,
+		Pack:           1,
+		Brain:          bossBrain(&Charger, 16),
+		Flocking:       Flocking{Radius: 320, Avoidance: 1},
+		Boss:           true,
+		Phases:         []float32{0.66, 0.33},
+	}
+)
+
+// enemyTypes lists the enemy types a stage file may ask for.
+var enemyTypes = map[string]*Archetype{
+	Ghost.Name:      &Ghost,
+	Shooter.Name:    &Shooter,
+	Splitter.Name:   &Splitter,
+	Splitling.Name:  &Splitling,
+	Tank.Name:       &Tank,
+	Charger.Name:    &Charger,
+	Swarmer.Name:    &Swarmer,
+	Warden.Name:     &Warden,
+	ColdKiller.Name: &ColdKiller,
+}
+
+// velocityTowards is the velocity that moves the enemy's middle straight at
+// target.
+func (e *Enemy) velocityTowards(target Vector2, speed float32) Vector2 {
+	center := e.Shape().Centroid()
+	d := Vector2{X: target.X - center.X, Y: target.Y - center.Y}
+	distance := length(d)
+	if distance == 0 {
+		return Vector2{}
+	}
+	return Vector2{X: d.X / distance * speed, Y: d.Y / distance * speed}
+}
+
+// distanceTo is how far the enemy's middle is from target.
+func (e *Enemy) distanceTo(target Vector2) float32 {
+	center := e.Shape().Centroid()
+	return length(Vector2{X: target.X - center.X, Y: target.Y - center.Y})
+}
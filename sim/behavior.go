@@ -0,0 +1,290 @@
+package sim
+
+import (
+	"math"
+	"time"
+)
+
+// Behavior is one way of moving, like chasing the player or wandering off.
+// Brains combine behaviors into archetypes.
+type Behavior struct {
+	Name string
+	// start runs when the behavior begins, to pick its speed and heading.
+	start func(e *Enemy, w *World)
+	// act sets the enemy's velocity for the coming step.
+	act func(e *Enemy, w *World)
+	// steady behaviors keep their line, flocking does not steer them.
+	steady bool
+}
+
+// Idle stands still.
+func Idle() Behavior {
+	return Behavior{
+		Name: "Idle",
+		act: func(e *Enemy, w *World) {
+			e.lastPlanVector = Vector2{}
+		},
+	}
+}
+
+// Chase runs at the player, around whatever is in the way.
+func Chase(speed Speed) Behavior {
+	return Behavior{
+		Name: "Chase",
+		start: func(e *Enemy, w *World) {
+			e.movementSpeed = speed.roll(w)
+		},
+		act: func(e *Enemy, w *World) {
+			e.lastPlanVector = e.towardsPlayer(w, e.movementSpeed)
+		},
+	}
+}
+
+// Rush chases the player in anger. From rushSpeed up the front end tints
+// the enemy red.
+func Rush(speed Speed) Behavior {
+	b := Chase(speed)
+	b.Name = "Rush"
+	return b
+}
+
+// Locked picks b's velocity once when it starts and keeps it for the whole
+// step, wherever the player goes meanwhile, like the original enemy did.
+func Locked(b Behavior) Behavior {
+	start, act := b.start, b.act
+	b.start = func(e *Enemy, w *World) {
+		if start != nil {
+			start(e, w)
+		}
+		act(e, w)
+		e.heading = Vector2{}
+		e.movementSpeed = length(e.lastPlanVector)
+		if e.movementSpeed > 0 {
+			e.heading = Vector2{X: e.lastPlanVector.X / e.movementSpeed, Y: e.lastPlanVector.Y / e.movementSpeed}
+		}
+	}
+	b.act = func(e *Enemy, w *World) {
+		e.lastPlanVector = Vector2{X: e.heading.X * e.movementSpeed, Y: e.heading.Y * e.movementSpeed}
+	}
+	return b
+}
+
+// Flee runs straight away from the player.
+func Flee(speed Speed) Behavior {
+	return Behavior{
+		Name: "Flee",
+		start: func(e *Enemy, w *World) {
+			e.movementSpeed = speed.roll(w)
+		},
+		act: func(e *Enemy, w *World) {
+			toward := e.velocityTowards(w.player.Shape().Centroid(), e.movementSpeed)
+			e.lastPlanVector = Vector2{X: -toward.X, Y: -toward.Y}
+		},
+	}
+}
+
+// compass is the eight directions Wander picks from, starting up and going
+// clockwise.
+var compass = [8]Vector2{
+	{X: 0, Y: -1},
+	{X: 1, Y: -1},
+	{X: 1, Y: 0},
+	{X: 1, Y: 1},
+	{X: 0, Y: 1},
+	{X: -1, Y: 1},
+	{X: -1, Y: 0},
+	{X: -1, Y: -1},
+}
+
+// Wander walks in one of eight directions picked at random.
+func Wander(speed Speed) Behavior {
+	return Behavior{
+		Name: "Wander",
+		start: func(e *Enemy, w *World) {
+			e.movementSpeed = speed.roll(w)
+			direction := compass[w.rng.Intn(len(compass))]
+			mag := length(direction)
+			e.heading = Vector2{X: direction.X / mag, Y: direction.Y / mag}
+		},
+		act: func(e *Enemy, w *World) {
+			e.lastPlanVector = Vector2{X: e.heading.X * e.movementSpeed, Y: e.heading.Y * e.movementSpeed}
+		},
+	}
+}
+
+// Strafe circles the player, half of the enemies one way round and half the
+// other.
+func Strafe(speed Speed) Behavior {
+	return Behavior{
+		Name: "Strafe",
+		start: func(e *Enemy, w *World) {
+			e.movementSpeed = speed.roll(w)
+		},
+		act: func(e *Enemy, w *World) {
+			toward := e.velocityTowards(w.player.Shape().Centroid(), e.movementSpeed)
+			if e.id%2 == 0 {
+				e.lastPlanVector = Vector2{X: -toward.Y, Y: toward.X}
+			} else {
+				e.lastPlanVector = Vector2{X: toward.Y, Y: -toward.X}
+			}
+		},
+	}
+}
+
+// goldenAngle spreads surround slots evenly however many enemies share
+// them.
+const goldenAngle = 2.39996
+
+// Surround moves to the enemy's own slot on a ring of radius around the
+// player, so a group closes in from every side at once. Each enemy finds
+// its own way to its slot.
+func Surround(speed Speed, radius float32) Behavior {
+	return Behavior{
+		Name: "Surround",
+		start: func(e *Enemy, w *World) {
+			e.movementSpeed = speed.roll(w)
+		},
+		act: func(e *Enemy, w *World) {
+			player := w.player.Shape().Centroid()
+			angle := float64(e.id) * goldenAngle
+			slot := Vector2{
+				X: player.X + float32(math.Cos(angle))*radius,
+				Y: player.Y + float32(math.Sin(angle))*radius,
+			}
+			// slow down on arrival instead of jittering around the slot
+			speed := min(e.movementSpeed, e.distanceTo(slot)*5)
+			e.lastPlanVector = e.towards(w, slot, speed)
+		},
+	}
+}
+
+// WindUp stands still and locks the heading and distance to the player for
+// a Dash to follow. The front end warns the player while it lasts.
+func WindUp() Behavior {
+	return Behavior{
+		Name:   "WindUp",
+		steady: true,
+		start: func(e *Enemy, w *World) {
+			player := w.player.Shape().Centroid()
+			e.heading = e.velocityTowards(player, 1)
+			e.dashDistance = e.distanceTo(player)
+		},
+		act: func(e *Enemy, w *World) {
+			e.lastPlanVector = Vector2{}
+		},
+	}
+}
+
+// Dash runs along the heading WindUp locked, no matter where the player
+// went since, through where the player stood and overshoot beyond. It ends
+// there by itself, whatever its step says.
+func Dash(speed Speed, overshoot float32) Behavior {
+	return Behavior{
+		Name:   "Dash",
+		steady: true,
+		start: func(e *Enemy, w *World) {
+			e.movementSpeed = speed.roll(w)
+			if e.movementSpeed > 0 {
+				seconds := (e.dashDistance + overshoot) / e.movementSpeed
+				// at least one step, a zero duration would never end
+				e.mind.duration = max(time.Duration(float64(seconds)*float64(time.Second)), FixedStep)
+			}
+		},
+		act: func(e *Enemy, w *World) {
+			e.lastPlanVector = Vector2{X: e.heading.X * e.movementSpeed, Y: e.heading.Y * e.movementSpeed}
+		},
+	}
+}
+
+// TelegraphedRush is a rule that winds up for windUp, then dashes at speed
+// through where the player stood and overshoot beyond, then catches its
+// breath for recover. Once started it cannot be interrupted.
+func TelegraphedRush(when Condition, windUp time.Duration, speed Speed, overshoot float32, recover time.Duration) Rule {
+	steps := []Step{
+		Hold(WindUp(), windUp),
+		Hold(Dash(speed, overshoot), 0),
+	}
+	if recover > 0 {
+		steps = append(steps, Hold(Idle(), recover))
+	}
+	return Rule{
+		When:     when,
+		Steps:    steps,
+		Sequence: true,
+		Commit:   true,
+	}
+}
+
+// Burst stands still and fires count shots in a ring, turned by a random
+// amount each time so the gaps are never in the same place.
+func Burst(count int, shotSpeed float32) Behavior {
+	return Behavior{
+		Name:   "Burst",
+		steady: true,
+		start: func(e *Enemy, w *World) {
+			center := e.Shape().Centroid()
+			step := 2 * math.Pi / float64(count)
+			offset := w.rng.Float64() * step
+			for i := 0; i < count; i++ {
+				angle := offset + float64(i)*step
+				target := Vector2{
+					X: center.X + float32(math.Cos(angle)),
+					Y: center.Y + float32(math.Sin(angle)),
+				}
+				w.createEnemyBullet(center, target, shotSpeed)
+			}
+		},
+		act: func(e *Enemy, w *World) {
+			e.lastPlanVector = Vector2{}
+		},
+	}
+}
+
+// Summon stands still and calls count enemies of archetype to its side, as
+// long as fewer than maxAdds others are around. They take spawnIn to
+// appear like a wave.
+func Summon(archetype *Archetype, count int) Behavior {
+	return Behavior{
+		Name:   "Summon",
+		steady: true,
+		start: func(e *Enemy, w *World) {
+			others := -1
+			for _, obj := range w.gameObjects {
+				if obj.IsEnemy() {
+					others++
+				}
+			}
+			center := e.Shape().Centroid()
+			reach := e.sourceRec.Width
+			for i := 0; i < count && others < maxAdds; i++ {
+				angle := w.rng.Float64() * 2 * math.Pi
+				position := Vector2{
+					X: clamp(center.X+float32(math.Cos(angle))*reach-archetype.Size/2, 0, w.Width-archetype.Size),
+					Y: clamp(center.Y+float32(math.Sin(angle))*reach-archetype.Size/2, 0, w.Height-archetype.Size),
+				}
+				w.spawnPack(archetype, position, spawnIn)
+				others += archetype.Pack
+			}
+		},
+		act: func(e *Enemy, w *World) {
+			e.lastPlanVector = Vector2{}
+		},
+	}
+}
+
+// Shooting adds to b a slow shot at the player every interval or so, as
+// long as the enemy can see the player.
+func Shooting(b Behavior, shotSpeed float32, interval time.Duration) Behavior {
+	move := b.act
+	canSee := LineOfSight()
+	b.act = func(e *Enemy, w *World) {
+		move(e, w)
+		if w.clock < e.nextShot || !canSee(e, w) {
+			return
+		}
+		w.createEnemyBullet(e.Shape().Centroid(), w.player.Shape().Centroid(), shotSpeed)
+		// up to half an interval late, so a group does not fire in volleys
+		e.nextShot = w.clock + interval + time.Duration(w.rng.Int63n(int64(interval/2)))
+	}
+	return b
+}
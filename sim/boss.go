@@ -0,0 +1,61 @@
+package sim
+
+import "time"
+
+// bossDefeat is how long a defeated boss takes to go down before the stage
+// is cleared.
+const bossDefeat = time.Duration(2500) * time.Millisecond
+
+// maxAdds caps the enemies a summoning boss keeps around it.
+const maxAdds = 8
+
+// Boss returns the boss of the stage, nil if there is none. With several,
+// it is the first one spawned.
+func (w *World) Boss() *Enemy {
+	for _, obj := range w.Objects() {
+		if e, ok := obj.(*Enemy); ok && e.archetype.Boss {
+			return e
+		}
+	}
+	return nil
+}
+
+// defeatBoss starts the defeat sequence of e: it stops fighting, and its
+// adds, its shots and the waves still to come go with it.
+func (w *World) defeatBoss(e *Enemy, events *Events) {
+	events.BossDefeated = true
+	w.noteKill()
+	e.hp = 0
+	e.dyingLeft = bossDefeat
+	e.lastPlanVector = Vector2{}
+	e.knockback = Vector2{}
+	e.flashLeft = 0
+	w.waves = w.waves[:0]
+	for _, obj := range w.Objects() {
+		switch {
+		case obj == e:
+		case obj.IsEnemy():
+			other := obj.(*Enemy)
+			// another boss still going down keeps its own sequence
+			if other.IsDying() {
+				continue
+			}
+			delete(w.gameObjects, obj.GameObjectId())
+			w.createDead(other.prevPosition, other.sourceRec.Width)
+		case isHostileBullet(obj):
+			delete(w.gameObjects, obj.GameObjectId())
+		}
+	}
+}
+
+// buryDefeated removes the bosses whose defeat sequence is over.
+func (w *World) buryDefeated() {
+	for _, obj := range w.Objects() {
+		e, ok := obj.(*Enemy)
+		if !ok || e.dyingLeft > 0 || !e.archetype.Boss || e.hp > 0 {
+			continue
+		}
+		delete(w.gameObjects, e.id)
+		w.createDead(e.prevPosition, e.sourceRec.Width)
+	}
+}
@@ -0,0 +1,77 @@
+package sim
+
+import "time"
+
+type Bullet struct {
+	id            int
+	sourceRec     Rectangle
+	position      Vector2
+	prevPosition  Vector2
+	movementSpeed float32
+	// units per second
+	vector Vector2
+	damage int
+	// knockback is the speed, in units per second, the bullet pushes what
+	// it hits with.
+	knockback float32
+	// hostile bullets were fired by enemies and hurt the player instead.
+	hostile bool
+	// bounces counts the ricochets off ice walls so far.
+	bounces int
+}
+
+// IsHostile reports whether an enemy fired the bullet.
+func (b *Bullet) IsHostile() bool {
+	return b.hostile
+}
+
+func (b *Bullet) SpriteRect() Rectangle {
+	return Rectangle{
+		X:      b.position.X,
+		Y:      b.position.Y,
+		Width:  b.sourceRec.Width,
+		Height: b.sourceRec.Height,
+	}
+}
+
+// Shape is the circle inscribed in the diamond sprite.
+func (b *Bullet) Shape() Shape {
+	return CircleShape(b.center(b.position), b.sourceRec.Width/2)
+}
+
+func (b *Bullet) center(position Vector2) Vector2 {
+	return Vector2{
+		X: position.X + b.sourceRec.Width/2,
+		Y: position.Y + b.sourceRec.Height/2,
+	}
+}
+
+func (b *Bullet) Position() Vector2 {
+	return b.position
+}
+
+func (b *Bullet) GameObjectId() int {
+	return b.id
+}
+
+func (b *Bullet) IsEnemy() bool {
+	return false
+}
+
+func (b *Bullet) IsBullet() bool {
+	return true
+}
+
+func (b *Bullet) Move(dt time.Duration) {
+	seconds := float32(dt.Seconds())
+	b.prevPosition = b.position
+	b.position.X += b.vector.X * seconds
+	b.position.Y += b.vector.Y * seconds
+}
+
+func (b *Bullet) EnemyPlan(w *World) {
+}
+
+func (b *Bullet) PrevPosition() Vector2 {
+	return b.prevPosition
+}
@@ -0,0 +1,48 @@
+package sim
+
+import "time"
+
+type Dead struct {
+	id        int
+	sourceRec Rectangle
+	position  Vector2
+}
+
+func (d *Dead) SpriteRect() Rectangle {
+	return Rectangle{
+		X:      d.position.X,
+		Y:      d.position.Y,
+		Width:  d.sourceRec.Width,
+		Height: d.sourceRec.Height,
+	}
+}
+
+func (d *Dead) Shape() Shape {
+	return RectShape(d.SpriteRect())
+}
+
+func (d *Dead) Position() Vector2 {
+	return d.position
+}
+
+func (d *Dead) GameObjectId() int {
+	return d.id
+}
+
+func (d *Dead) IsEnemy() bool {
+	return false
+}
+
+func (d *Dead) IsBullet() bool {
+	return false
+}
+
+func (d *Dead) Move(dt time.Duration) {
+}
+
+func (d *Dead) EnemyPlan(w *World) {
+}
+
+func (d *Dead) PrevPosition() Vector2 {
+	return d.position
+}
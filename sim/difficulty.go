@@ -0,0 +1,59 @@
+package sim
+
+import "time"
+
+// Difficulty decides what happens when an enemy touches the player.
+type Difficulty struct {
+	Name string
+	// MaxHP is the player's health pool. 0 keeps the original rule: the
+	// first touch ends the run.
+	MaxHP         int
+	ContactDamage int
+	// Knockback is the speed, in units per second, the player is pushed
+	// away from the enemy with.
+	Knockback float32
+	// Invulnerability is how long the player cannot be hurt after a hit.
+	Invulnerability time.Duration
+}
+
+var (
+	OneHit = Difficulty{
+		Name: "One Hit",
+	}
+	ThreeLives = Difficulty{
+		Name:            "Three Lives",
+		MaxHP:           3,
+		ContactDamage:   1,
+		Knockback:       2400,
+		Invulnerability: time.Duration(1500) * time.Millisecond,
+	}
+	FiveLives = Difficulty{
+		Name:            "Five Lives",
+		MaxHP:           5,
+		ContactDamage:   1,
+		Knockback:       2400,
+		Invulnerability: time.Duration(2000) * time.Millisecond,
+	}
+)
+
+// Difficulties lists the rules a run can be played with, hardest first.
+var Difficulties = []Difficulty{OneHit, ThreeLives, FiveLives}
+
+// DifficultyByName finds a difficulty in Difficulties.
+func DifficultyByName(name string) (Difficulty, bool) {
+	for _, d := range Difficulties {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Difficulty{}, false
+}
+
+// Lives is how many more hits the player can take, counting the one that
+// would end the run.
+func (d Difficulty) Lives(hp int) int {
+	if d.MaxHP == 0 {
+		return 1
+	}
+	return (hp + d.ContactDamage - 1) / d.ContactDamage
+}
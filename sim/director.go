@@ -0,0 +1,195 @@
+package sim
+
+import "time"
+
+// Mode is how a run is played.
+type Mode string
+
+const (
+	// Campaign plays the stages in order until the last one is cleared.
+	Campaign Mode = "campaign"
+	// Endless plays the arena of the first stage while the director keeps
+	// sending enemies, until the player dies.
+	Endless Mode = "endless"
+)
+
+// Modes lists the ways a run can be played.
+var Modes = []Mode{Campaign, Endless}
+
+// ModeByName finds a mode in Modes.
+func ModeByName(name string) (Mode, bool) {
+	for _, m := range Modes {
+		if string(m) == name {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+const (
+	// directorRamp is how long it takes the pressure to grow by one on
+	// survival time alone.
+	directorRamp = time.Minute
+	// directorWindow is how far back the director looks at kills.
+	directorWindow = time.Duration(10) * time.Second
+	// baseSpawnInterval is the time between two spawns at no pressure.
+	baseSpawnInterval = time.Duration(3) * time.Second
+	minSpawnInterval  = time.Duration(400) * time.Millisecond
+	// closeCallDistance is how near, in units, a threat must pass the
+	// player's body to count as a close call.
+	closeCallDistance = 60
+	// closeCallRelief is how long the director eases off after a close
+	// call.
+	closeCallRelief = time.Duration(4) * time.Second
+	// maxSpeedScale caps how much faster than usual enemies get.
+	maxSpeedScale = 1.75
+)
+
+// endlessTiers is the order archetypes join the endless mix in, and the
+// pressure each needs.
+var endlessTiers = []struct {
+	pressure  float32
+	archetype *Archetype
+}{
+	{0, &Ghost},
+	{0.5, &Swarmer},
+	{1, &Shooter},
+	{1.5, &Splitter},
+	{2, &Charger},
+	{3, &Tank},
+}
+
+// director runs endless mode. It raises the pressure with the time
+// survived and the recent kill rate, and lets off while the player is in
+// trouble.
+type director struct {
+	nextSpawn time.Duration
+	// kills are the times of the kills within directorWindow.
+	kills []time.Duration
+	// reliefUntil is when the ease off after the last close call ends.
+	reliefUntil time.Duration
+}
+
+// SetMode picks how the next run is played.
+func (w *World) SetMode(m Mode) {
+	w.mode = m
+}
+
+func (w *World) Mode() Mode {
+	return w.mode
+}
+
+// Kills is how many enemies the player killed, in endless mode since the
+// arena started.
+func (w *World) Kills() int {
+	return w.kills
+}
+
+// Score is the endless mode score: one point per second survived and one
+// per kill.
+func (w *World) Score() int {
+	return int((w.clock-w.stageStartTime)/time.Second) + w.kills
+}
+
+// startEndless clears the director for a fresh endless arena.
+func (w *World) startEndless() {
+	w.kills = 0
+	w.director = director{nextSpawn: w.clock + time.Second}
+}
+
+// pressure is how hard the director pushes right now.
+func (w *World) pressure() float32 {
+	d := &w.director
+	survived := float32(w.clock-w.stageStartTime) / float32(directorRamp)
+	killRate := float32(len(d.kills)) / float32(directorWindow.Seconds())
+	pressure := survived + killRate
+	if w.clock < d.reliefUntil {
+		pressure /= 2
+	}
+	return pressure
+}
+
+// speedScale multiplies the speed of every behavior that starts. Only the
+// director changes it.
+func (w *World) speedScale() float32 {
+	if w.mode != Endless {
+		return 1
+	}
+	return min(1+0.15*w.pressure(), maxSpeedScale)
+}
+
+// noteKill tells the director about a kill.
+func (w *World) noteKill() {
+	w.kills++
+	if w.mode == Endless {
+		w.director.kills = append(w.director.kills, w.clock)
+	}
+}
+
+// direct is the director's step: it watches for close calls, forgets old
+// kills and spawns the next enemy when it is time. The grid must be up to
+// date.
+func (w *World) direct(events *Events) {
+	d := &w.director
+	if events.PlayerHit || w.closeCall() {
+		d.reliefUntil = w.clock + closeCallRelief
+	}
+	recent := d.kills[:0]
+	for _, at := range d.kills {
+		if w.clock-at < directorWindow {
+			recent = append(recent, at)
+		}
+	}
+	d.kills = recent
+
+	if w.clock < d.nextSpawn {
+		return
+	}
+	pressure := w.pressure()
+	interval := time.Duration(float32(baseSpawnInterval) / (1 + pressure))
+	d.nextSpawn = w.clock + max(interval, minSpawnInterval)
+
+	alive := 0
+	for _, obj := range w.gameObjects {
+		if obj.IsEnemy() {
+			alive++
+		}
+	}
+	if alive >= min(8+int(6*pressure), 60) {
+		return
+	}
+	var unlocked []*Archetype
+	for _, tier := range endlessTiers {
+		if pressure >= tier.pressure {
+			unlocked = append(unlocked, tier.archetype)
+		}
+	}
+	archetype := unlocked[w.rng.Intn(len(unlocked))]
+	position := w.generateEdgePosition(w.player.Shape().Centroid(), archetype.Size, archetype.Size, defaultMinDistance)
+	w.spawnPack(archetype, position, spawnIn)
+}
+
+// closeCall reports whether an enemy or an enemy shot is passing the player
+// within closeCallDistance.
+func (w *World) closeCall() bool {
+	area := w.player.Shape().Bounds()
+	area.X -= closeCallDistance
+	area.Y -= closeCallDistance
+	area.Width += closeCallDistance * 2
+	area.Height += closeCallDistance * 2
+	for _, obj := range w.enemiesNear(area, nil) {
+		if e, ok := obj.(*Enemy); ok && !e.inPlay() {
+			continue
+		}
+		if !obj.IsEnemy() && !isHostileBullet(obj) {
+			continue
+		}
+		if _, alive := w.gameObjects[obj.GameObjectId()]; !alive {
+			continue
+		}
+		if CheckCollisionRecs(area, obj.Shape().Bounds()) {
+			return true
+		}
+	}
+	return false
+}
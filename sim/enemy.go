@@ -0,0 +1,218 @@
+package sim
+
+import "time"
+
+// rushSpeed is the speed, in units per second, from which a rushing enemy
+// counts as angry.
+const rushSpeed = 1800
+
+// enemyBody is the part of the 100x100 enemy sprite that hurts: the ghost
+// without the transparent corners around its pointed head. Bigger and
+// smaller archetypes scale it with the sprite.
+var enemyBody = Rectangle{X: 15, Y: 8, Width: 70, Height: 90}
+
+// hitFlash is how long an enemy flashes after a bullet hurt it.
+const hitFlash = time.Duration(100) * time.Millisecond
+
+type Enemy struct {
+	id           int
+	archetype    *Archetype
+	sourceRec    Rectangle
+	position     Vector2
+	prevPosition Vector2
+	// movementSpeed is what the running behavior picked, in units per
+	// second.
+	movementSpeed float32
+	// units per second
+	lastPlanVector Vector2
+	mind           mind
+	// heading is the unit vector Wander, Dash and Locked behaviors move along.
+	heading Vector2
+	// dashDistance is how far the player was when WindUp locked heading.
+	dashDistance float32
+	// path holds the waypoints of the A* path still to reach. replanAt is
+	// when it may be searched again.
+	path     []Vector2
+	replanAt time.Duration
+
+	hp int
+	// flashLeft counts down after a hit that did not kill.
+	flashLeft time.Duration
+	// spawnLeft counts down while a wave enemy appears.
+	spawnLeft time.Duration
+	// dyingLeft counts down while a defeated boss goes down.
+	dyingLeft time.Duration
+	// knockback is the velocity, in units per second, of the push from the
+	// last hits. It fades out like the player's.
+	knockback Vector2
+
+	// nextShot is when a Shooting behavior fires next.
+	nextShot time.Duration
+}
+
+// hurt applies one bullet hit and reports whether it killed the enemy.
+func (e *Enemy) hurt(b *Bullet) bool {
+	e.hp -= b.damage
+	if e.hp <= 0 {
+		return true
+	}
+	e.flashLeft = hitFlash
+	if speed := length(b.vector); speed != 0 {
+		push := b.knockback * e.archetype.KnockbackScale
+		e.knockback.X += b.vector.X / speed * push
+		e.knockback.Y += b.vector.Y / speed * push
+	}
+	return false
+}
+
+func (e *Enemy) isOutOfArena(w *World) bool {
+	hb := e.Shape().Bounds()
+	if hb.X+hb.Width < 0 || hb.X > w.Width ||
+		hb.Y+hb.Height < 0 || hb.Y > w.Height {
+		return true
+	}
+	return false
+}
+
+// IsRushing reports whether the enemy is in an angry rush or dash, which
+// the front end draws with a red tint.
+func (e *Enemy) IsRushing() bool {
+	behavior := e.Behavior()
+	return (behavior == "Rush" || behavior == "Dash") && e.movementSpeed >= rushSpeed
+}
+
+// IsWindingUp reports whether the enemy is about to dash along
+// DashDirection.
+func (e *Enemy) IsWindingUp() bool {
+	return e.Behavior() == "WindUp"
+}
+
+// DashDirection is the unit vector a winding up enemy will dash along.
+func (e *Enemy) DashDirection() Vector2 {
+	return e.heading
+}
+
+// Behavior is the name of what the enemy is doing, empty before it first
+// thinks.
+func (e *Enemy) Behavior() string {
+	if e.mind.behavior == nil {
+		return ""
+	}
+	return e.mind.behavior.Name
+}
+
+// IsFlashing reports whether the enemy was just hurt, which the front end
+// draws as a white flash.
+func (e *Enemy) IsFlashing() bool {
+	return e.flashLeft > 0
+}
+
+// IsSpawning reports whether the enemy is still appearing. It cannot hurt
+// or be hurt yet.
+func (e *Enemy) IsSpawning() bool {
+	return e.spawnLeft > 0
+}
+
+// IsDying reports whether the enemy is a defeated boss going down.
+func (e *Enemy) IsDying() bool {
+	return e.dyingLeft > 0
+}
+
+// DefeatProgress goes from 0 when a boss is defeated to 1 when it is gone.
+func (e *Enemy) DefeatProgress() float32 {
+	return 1 - float32(e.dyingLeft)/float32(bossDefeat)
+}
+
+// inPlay reports whether the enemy takes part in the fight: it is neither
+// appearing nor going down.
+func (e *Enemy) inPlay() bool {
+	return e.spawnLeft <= 0 && e.dyingLeft <= 0
+}
+
+// Phase is the phase of a boss's fight, from 1, going up each time its
+// health falls below one of Archetype.Phases. Other enemies stay in phase
+// 1.
+func (e *Enemy) Phase() int {
+	phase := 1
+	for _, threshold := range e.archetype.Phases {
+		if float32(e.hp) < threshold*float32(e.archetype.HP) {
+			phase++
+		}
+	}
+	return phase
+}
+
+// SpawnProgress goes from 0 when the enemy starts to appear to 1 once it
+// is there.
+func (e *Enemy) SpawnProgress() float32 {
+	return 1 - float32(e.spawnLeft)/float32(spawnIn)
+}
+
+func (e *Enemy) HP() int {
+	return e.hp
+}
+
+func (e *Enemy) Archetype() *Archetype {
+	return e.archetype
+}
+
+func (e *Enemy) SpriteRect() Rectangle {
+	return Rectangle{
+		X:      e.position.X,
+		Y:      e.position.Y,
+		Width:  e.sourceRec.Width,
+		Height: e.sourceRec.Height,
+	}
+}
+
+func (e *Enemy) Shape() Shape {
+	scale := e.sourceRec.Width / 100
+	return RectShape(Rectangle{
+		X:      e.position.X + enemyBody.X*scale,
+		Y:      e.position.Y + enemyBody.Y*scale,
+		Width:  enemyBody.Width * scale,
+		Height: enemyBody.Height * scale,
+	})
+}
+
+func (e *Enemy) Position() Vector2 {
+	return e.position
+}
+
+func (e *Enemy) GameObjectId() int {
+	return e.id
+}
+
+func (e *Enemy) IsEnemy() bool {
+	return true
+}
+
+func (e *Enemy) IsBullet() bool {
+	return false
+}
+
+func (e *Enemy) Move(dt time.Duration) {
+	seconds := float32(dt.Seconds())
+	e.prevPosition = e.position
+	e.position.X += (e.lastPlanVector.X + e.knockback.X) * seconds
+	e.position.Y += (e.lastPlanVector.Y + e.knockback.Y) * seconds
+
+	fade := max(1-knockbackFriction*seconds, 0)
+	e.knockback.X *= fade
+	e.knockback.Y *= fade
+	e.flashLeft = max(e.flashLeft-dt, 0)
+	e.spawnLeft = max(e.spawnLeft-dt, 0)
+	e.dyingLeft = max(e.dyingLeft-dt, 0)
+}
+
+func (e *Enemy) EnemyPlan(w *World) {
+	if !e.inPlay() {
+		e.lastPlanVector = Vector2{}
+		return
+	}
+	e.think(w)
+}
+
+func (e *Enemy) PrevPosition() Vector2 {
+	return e.prevPosition
+}
@@ -0,0 +1,137 @@
+package sim
+
+// Flocking are the weights of the steering forces mixed into an enemy's
+// plan, so crowds spread around the player instead of stacking into one
+// blob. Zero turns a force off.
+type Flocking struct {
+	// Radius is how far, in units, an enemy looks for neighbors and
+	// obstacles.
+	Radius float32
+	// Separation pushes away from any enemy that is too close.
+	Separation float32
+	// Alignment matches the velocity of neighbors of the same archetype.
+	Alignment float32
+	// Cohesion pulls towards the middle of neighbors of the same
+	// archetype.
+	Cohesion float32
+	// Avoidance pushes away from obstacles.
+	Avoidance float32
+}
+
+// flockSpeed is the speed, in units per second, a steering force of weight
+// 1 adds at full strength.
+const flockSpeed = 500
+
+// flock mixes the steering forces into every enemy's plan. The forces are
+// worked out from where everyone is before any of them is applied, so the
+// result does not depend on the order enemies are visited in.
+func (w *World) flock() {
+	type steer struct {
+		enemy *Enemy
+		force Vector2
+	}
+	var steers []steer
+	for _, obj := range w.Objects() {
+		e, ok := obj.(*Enemy)
+		if !ok || e.archetype.Flocking.Radius <= 0 || !e.steerable() || !e.inPlay() {
+			continue
+		}
+		steers = append(steers, steer{enemy: e, force: w.steering(e)})
+	}
+	for _, s := range steers {
+		s.enemy.lastPlanVector.X += s.force.X
+		s.enemy.lastPlanVector.Y += s.force.Y
+	}
+}
+
+// steerable reports whether the running behavior lets steering change its
+// course. Wind-ups and dashes are committed to their line.
+func (e *Enemy) steerable() bool {
+	return e.mind.behavior == nil || !e.mind.behavior.steady
+}
+
+func (w *World) steering(e *Enemy) Vector2 {
+	weights := e.archetype.Flocking
+	radius := weights.Radius
+	center := e.Shape().Centroid()
+	area := Rectangle{X: center.X - radius, Y: center.Y - radius, Width: radius * 2, Height: radius * 2}
+
+	var separation, alignment, cohesion Vector2
+	flockmates := 0
+	for _, obj := range w.enemiesNear(area, nil) {
+		other, ok := obj.(*Enemy)
+		if !ok || other == e {
+			continue
+		}
+		if _, alive := w.gameObjects[other.id]; !alive {
+			continue
+		}
+		otherCenter := other.Shape().Centroid()
+		away := Vector2{X: center.X - otherCenter.X, Y: center.Y - otherCenter.Y}
+		distance := length(away)
+		if distance >= radius {
+			continue
+		}
+		if distance == 0 {
+			// exactly on top of each other, split them by id
+			away = Vector2{X: 1}
+			if e.id < other.id {
+				away.X = -1
+			}
+			distance = 1
+		}
+		closeness := 1 - distance/radius
+		separation.X += away.X / distance * closeness
+		separation.Y += away.Y / distance * closeness
+
+		if other.archetype == e.archetype {
+			flockmates++
+			alignment.X += other.lastPlanVector.X
+			alignment.Y += other.lastPlanVector.Y
+			cohesion.X += otherCenter.X
+			cohesion.Y += otherCenter.Y
+		}
+	}
+
+	force := Vector2{
+		X: separation.X * flockSpeed * weights.Separation,
+		Y: separation.Y * flockSpeed * weights.Separation,
+	}
+	if flockmates > 0 {
+		n := float32(flockmates)
+		force.X += (alignment.X/n - e.lastPlanVector.X) * weights.Alignment
+		force.Y += (alignment.Y/n - e.lastPlanVector.Y) * weights.Alignment
+		toMiddle := Vector2{X: cohesion.X/n - center.X, Y: cohesion.Y/n - center.Y}
+		if d := length(toMiddle); d > 0 {
+			force.X += toMiddle.X / d * flockSpeed * weights.Cohesion
+			force.Y += toMiddle.Y / d * flockSpeed * weights.Cohesion
+		}
+	}
+
+	avoid := w.avoidance(center, radius)
+	force.X += avoid.X * flockSpeed * weights.Avoidance
+	force.Y += avoid.Y * flockSpeed * weights.Avoidance
+	return force
+}
+
+// avoidance is the push away from every obstacle closer than radius to
+// center, stronger the closer it is.
+func (w *World) avoidance(center Vector2, radius float32) Vector2 {
+	var push Vector2
+	for _, o := range w.obstacles {
+		obstacle := o.Rect
+		closest := Vector2{
+			X: clamp(center.X, obstacle.X, obstacle.X+obstacle.Width),
+			Y: clamp(center.Y, obstacle.Y, obstacle.Y+obstacle.Height),
+		}
+		away := Vector2{X: center.X - closest.X, Y: center.Y - closest.Y}
+		distance := length(away)
+		if distance >= radius || distance == 0 {
+			continue
+		}
+		closeness := 1 - distance/radius
+		push.X += away.X / distance * closeness
+		push.Y += away.Y / distance * closeness
+	}
+	return push
+}
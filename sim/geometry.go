@@ -0,0 +1,125 @@
+package sim
+
+import "math"
+
+// Vector2 mirrors rl.Vector2 so the front end can convert between the two
+// with a plain type conversion.
+type Vector2 struct {
+	X float32
+	Y float32
+}
+
+// Rectangle mirrors rl.Rectangle.
+type Rectangle struct {
+	X      float32
+	Y      float32
+	Width  float32
+	Height float32
+}
+
+func length(v Vector2) float32 {
+	return float32(math.Sqrt(float64(v.X*v.X + v.Y*v.Y)))
+}
+
+// normalize scales v to length 1. The zero vector stays zero.
+func normalize(v Vector2) Vector2 {
+	l := length(v)
+	if l == 0 {
+		return v
+	}
+	return Vector2{X: v.X / l, Y: v.Y / l}
+}
+
+func clamp(value, min, max float32) float32 {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+func abs(value float32) float32 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func CheckCollisionRecs(rec1, rec2 Rectangle) bool {
+	return rec1.X < rec2.X+rec2.Width && rec1.X+rec1.Width > rec2.X &&
+		rec1.Y < rec2.Y+rec2.Height && rec1.Y+rec1.Height > rec2.Y
+}
+
+func pointInRect(p Vector2, rect Rectangle) bool {
+	return p.X >= rect.X && p.X <= rect.X+rect.Width &&
+		p.Y >= rect.Y && p.Y <= rect.Y+rect.Height
+}
+
+func lineSegmentsIntersect(p, p2, q, q2 Vector2) bool {
+	orientation := func(a, b, c Vector2) float32 {
+		return (b.Y-a.Y)*(c.X-a.X) - (b.X-a.X)*(c.Y-a.Y)
+	}
+	o1 := orientation(p, p2, q)
+	o2 := orientation(p, p2, q2)
+	o3 := orientation(q, q2, p)
+	o4 := orientation(q, q2, p2)
+
+	return o1*o2 < 0 && o3*o4 < 0
+}
+
+func lineIntersectsRect(p, q Vector2, rect Rectangle) bool {
+	if pointInRect(p, rect) || pointInRect(q, rect) {
+		return true
+	}
+
+	topLeft := Vector2{X: rect.X, Y: rect.Y}
+	topRight := Vector2{X: rect.X + rect.Width, Y: rect.Y}
+	bottomRight := Vector2{X: rect.X + rect.Width, Y: rect.Y + rect.Height}
+	bottomLeft := Vector2{X: rect.X, Y: rect.Y + rect.Height}
+
+	if lineSegmentsIntersect(p, q, topLeft, topRight) ||
+		lineSegmentsIntersect(p, q, topRight, bottomRight) ||
+		lineSegmentsIntersect(p, q, bottomRight, bottomLeft) ||
+		lineSegmentsIntersect(p, q, bottomLeft, topLeft) {
+		return true
+	}
+
+	return false
+}
+
+// segmentEntry returns how far along p to q, from 0 to 1, the segment first
+// enters rect and the outward normal of the side it enters through. A
+// segment that starts inside enters at 0, against its own direction.
+func segmentEntry(p, q Vector2, rect Rectangle) (t float32, normal Vector2, ok bool) {
+	d := Vector2{X: q.X - p.X, Y: q.Y - p.Y}
+	if pointInRect(p, rect) {
+		if mag := length(d); mag > 0 {
+			normal = Vector2{X: -d.X / mag, Y: -d.Y / mag}
+		}
+		return 0, normal, true
+	}
+	enter, exit := float32(0), float32(1)
+	slab := func(start, delta, low, high float32, side Vector2) bool {
+		if delta == 0 {
+			return start >= low && start <= high
+		}
+		near, far := (low-start)/delta, (high-start)/delta
+		if near > far {
+			near, far = far, near
+		} else {
+			side = Vector2{X: -side.X, Y: -side.Y}
+		}
+		if near > enter {
+			enter, normal = near, side
+		}
+		exit = min(exit, far)
+		return enter <= exit
+	}
+	if !slab(p.X, d.X, rect.X, rect.X+rect.Width, Vector2{X: 1}) ||
+		!slab(p.Y, d.Y, rect.Y, rect.Y+rect.Height, Vector2{Y: 1}) {
+		return 0, Vector2{}, false
+	}
+	return enter, normal, true
+}
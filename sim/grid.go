@@ -0,0 +1,83 @@
+package sim
+
+import (
+	"math"
+	"sort"
+)
+
+// enemyCellSize is a little larger than an enemy, so most enemies sit in at
+// most four cells.
+const enemyCellSize = 128
+
+type gridCell struct {
+	x, y int
+}
+
+// spatialGrid buckets objects by the uniform cells their bounds touch, so a
+// collision check only has to look at objects in the cells it touches
+// instead of every object in the world.
+type spatialGrid struct {
+	cellSize float32
+	cells    map[gridCell][]GameObject
+	// found is reused by query to avoid allocating every step.
+	found []GameObject
+}
+
+func newSpatialGrid(cellSize float32) *spatialGrid {
+	return &spatialGrid{
+		cellSize: cellSize,
+		cells:    make(map[gridCell][]GameObject),
+	}
+}
+
+// clear empties every cell but keeps the memory for the next step.
+func (g *spatialGrid) clear() {
+	for cell, objects := range g.cells {
+		g.cells[cell] = objects[:0]
+	}
+}
+
+func (g *spatialGrid) insert(obj GameObject, bounds Rectangle) {
+	minX, minY, maxX, maxY := g.cellRange(bounds)
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			cell := gridCell{x: x, y: y}
+			g.cells[cell] = append(g.cells[cell], obj)
+		}
+	}
+}
+
+// query returns the objects whose cells overlap area, each once and in id
+// order, so callers visit them in the same order as a full scan would. The
+// slice is only valid until the next query.
+func (g *spatialGrid) query(area Rectangle) []GameObject {
+	g.found = g.found[:0]
+	minX, minY, maxX, maxY := g.cellRange(area)
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			g.found = append(g.found, g.cells[gridCell{x: x, y: y}]...)
+		}
+	}
+	sort.Slice(g.found, func(i, j int) bool {
+		return g.found[i].GameObjectId() < g.found[j].GameObjectId()
+	})
+
+	// objects spanning several cells were found more than once
+	unique := g.found[:0]
+	for _, obj := range g.found {
+		if len(unique) > 0 && obj.GameObjectId() == unique[len(unique)-1].GameObjectId() {
+			continue
+		}
+		unique = append(unique, obj)
+	}
+	g.found = unique
+	return unique
+}
+
+func (g *spatialGrid) cellRange(area Rectangle) (minX, minY, maxX, maxY int) {
+	minX = int(math.Floor(float64(area.X / g.cellSize)))
+	minY = int(math.Floor(float64(area.Y / g.cellSize)))
+	maxX = int(math.Floor(float64((area.X + area.Width) / g.cellSize)))
+	maxY = int(math.Floor(float64((area.Y + area.Height) / g.cellSize)))
+	return minX, minY, maxX, maxY
+}
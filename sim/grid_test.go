@@ -0,0 +1,78 @@
+package sim
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// BenchmarkCollisions measures one step of the player and bullet collision
+// checks with enemies and bullets scattered over a 5000x5000 arena. The
+// naive runs test every object, as the checks did before the spatial grid.
+func BenchmarkCollisions(b *testing.B) {
+	crowds := []struct{ enemies, bullets int }{
+		{10, 20},
+		{100, 500},
+		{1000, 5000},
+	}
+	for _, crowd := range crowds {
+		for _, broadphase := range []bool{false, true} {
+			name := "naive"
+			if broadphase {
+				name = "grid"
+			}
+			b.Run(fmt.Sprintf("%d-enemies/%d-bullets/%s", crowd.enemies, crowd.bullets, name), func(b *testing.B) {
+				benchmarkCollisions(b, crowd.enemies, crowd.bullets, broadphase)
+			})
+		}
+	}
+}
+
+func benchmarkCollisions(b *testing.B, enemies, bullets int, broadphase bool) {
+	b.StopTimer()
+	for i := 0; i < b.N; i++ {
+		// hits kill, split and delete objects, so every step starts from a
+		// fresh crowd
+		w := crowdedWorld(enemies, bullets)
+		if !broadphase {
+			w.enemyGrid = nil
+		}
+		var events Events
+		b.StartTimer()
+		w.indexEnemies()
+		w.playerDeathCheck(&events)
+		w.bulletCollisionCheck(&events)
+		b.StopTimer()
+	}
+}
+
+// crowdedWorld scatters ghosts and splitters and bullets flying every which
+// way over a 5000x5000 arena, the same way every time.
+func crowdedWorld(enemies, bullets int) *World {
+	w := NewWorld(5000, 5000, 1, nil)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < enemies; i++ {
+		archetype := &Ghost
+		if i%2 == 1 {
+			archetype = &Splitter
+		}
+		w.createEnemy(archetype, Vector2{X: rng.Float32() * 4900, Y: rng.Float32() * 4900})
+	}
+	for i := 0; i < bullets; i++ {
+		position := Vector2{X: rng.Float32() * 4990, Y: rng.Float32() * 4990}
+		vector := Vector2{X: rng.Float32()*2 - 1, Y: rng.Float32()*2 - 1}
+		w.gameObjects[w.nextGameObjectId] = &Bullet{
+			id:            w.nextGameObjectId,
+			sourceRec:     Rectangle{X: 0, Y: 0, Width: 10, Height: 10},
+			position:      position,
+			prevPosition:  Vector2{X: position.X - vector.X*100, Y: position.Y - vector.Y*100},
+			movementSpeed: Pistol.ProjectileSpeed,
+			vector:        Vector2{X: vector.X * Pistol.ProjectileSpeed, Y: vector.Y * Pistol.ProjectileSpeed},
+			// every hit kills, so splitters split
+			damage:    Splitter.HP,
+			knockback: Pistol.Knockback,
+		}
+		w.nextGameObjectId++
+	}
+	return w
+}
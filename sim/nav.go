@@ -0,0 +1,349 @@
+package sim
+
+import (
+	"container/heap"
+	"time"
+)
+
+// navCellSize is the side of a navigation cell, about half an enemy, so
+// gaps an enemy fits through stay open.
+const navCellSize = 64
+
+// navClearance is how far, in units, paths keep an enemy's middle from the
+// obstacles, so its body does not scrape along them.
+const navClearance = 40
+
+// Costs of a step to a neighboring cell, straight and diagonal, in tenths
+// of a cell.
+const (
+	straightCost = 10
+	diagonalCost = 14
+)
+
+// maxPathNodes bounds the cells A* expands, so one unreachable goal cannot
+// stall a step.
+const maxPathNodes = 4000
+
+// flowRefresh is the least time between two rebuilds of the flow field.
+const flowRefresh = time.Duration(200) * time.Millisecond
+
+// pathRefresh is the least time between two A* searches of one enemy.
+const pathRefresh = time.Duration(500) * time.Millisecond
+
+// navGrid is the arena cut into cells, each walkable or not. It only knows
+// about rectangles and points, so it can be built and queried without a
+// world.
+type navGrid struct {
+	cellSize   float32
+	cols, rows int
+	blocked    []bool
+}
+
+// neighborOffsets are the eight neighbors of a cell, straight ones first, in
+// a fixed order so ties always break the same way.
+var neighborOffsets = [8]struct{ dx, dy, cost int }{
+	{0, -1, straightCost},
+	{1, 0, straightCost},
+	{0, 1, straightCost},
+	{-1, 0, straightCost},
+	{1, -1, diagonalCost},
+	{1, 1, diagonalCost},
+	{-1, 1, diagonalCost},
+	{-1, -1, diagonalCost},
+}
+
+// newNavGrid cuts a width by height arena into cells and blocks every cell
+// that comes closer than clearance to an obstacle.
+func newNavGrid(width, height, cellSize float32, obstacles []Rectangle, clearance float32) *navGrid {
+	g := &navGrid{
+		cellSize: cellSize,
+		cols:     max(1, int(width/cellSize+0.999)),
+		rows:     max(1, int(height/cellSize+0.999)),
+	}
+	g.blocked = make([]bool, g.cols*g.rows)
+	for _, o := range obstacles {
+		grown := Rectangle{
+			X:      o.X - clearance,
+			Y:      o.Y - clearance,
+			Width:  o.Width + clearance*2,
+			Height: o.Height + clearance*2,
+		}
+		for y := 0; y < g.rows; y++ {
+			for x := 0; x < g.cols; x++ {
+				cell := Rectangle{X: float32(x) * cellSize, Y: float32(y) * cellSize, Width: cellSize, Height: cellSize}
+				if CheckCollisionRecs(cell, grown) {
+					g.blocked[y*g.cols+x] = true
+				}
+			}
+		}
+	}
+	return g
+}
+
+// cellAt returns the index of the cell p is in, clamped to the grid.
+func (g *navGrid) cellAt(p Vector2) int {
+	x := int(clamp(p.X/g.cellSize, 0, float32(g.cols-1)))
+	y := int(clamp(p.Y/g.cellSize, 0, float32(g.rows-1)))
+	return y*g.cols + x
+}
+
+// center is the middle of cell i in world units.
+func (g *navGrid) center(i int) Vector2 {
+	return Vector2{
+		X: (float32(i%g.cols) + 0.5) * g.cellSize,
+		Y: (float32(i/g.cols) + 0.5) * g.cellSize,
+	}
+}
+
+// neighbors calls visit for every walkable neighbor of cell i. Diagonal
+// steps must not cut the corner of a blocked cell.
+func (g *navGrid) neighbors(i int, visit func(j, cost int)) {
+	x, y := i%g.cols, i/g.cols
+	for _, n := range neighborOffsets {
+		nx, ny := x+n.dx, y+n.dy
+		if nx < 0 || ny < 0 || nx >= g.cols || ny >= g.rows {
+			continue
+		}
+		j := ny*g.cols + nx
+		if g.blocked[j] {
+			continue
+		}
+		if n.dx != 0 && n.dy != 0 && (g.blocked[y*g.cols+nx] || g.blocked[ny*g.cols+x]) {
+			continue
+		}
+		visit(j, n.cost)
+	}
+}
+
+// findPath searches the cheapest way from from to to with A* and returns
+// the middles of the cells along it, without the cell from is in. The goal
+// cell may be blocked, the player can stand closer to an obstacle than the
+// clearance. It returns nil if there is no way or the search gives up.
+func (g *navGrid) findPath(from, to Vector2) []Vector2 {
+	start, goal := g.cellAt(from), g.cellAt(to)
+	if start == goal {
+		return nil
+	}
+	cost := make(map[int]int)
+	cameFrom := make(map[int]int)
+	open := &navQueue{}
+	cost[start] = 0
+	heap.Push(open, navNode{cell: start, priority: g.estimate(start, goal)})
+	for expanded := 0; open.Len() > 0 && expanded < maxPathNodes; expanded++ {
+		current := heap.Pop(open).(navNode)
+		if current.cell == goal {
+			return g.walkBack(cameFrom, start, goal)
+		}
+		if current.priority > cost[current.cell]+g.estimate(current.cell, goal) {
+			// a stale entry, the cell was reached more cheaply since
+			continue
+		}
+		visit := func(j, step int) {
+			next := cost[current.cell] + step
+			if known, seen := cost[j]; seen && known <= next {
+				return
+			}
+			cost[j] = next
+			cameFrom[j] = current.cell
+			heap.Push(open, navNode{cell: j, priority: next + g.estimate(j, goal)})
+		}
+		g.neighbors(current.cell, visit)
+		if g.blocked[goal] && g.adjacent(current.cell, goal) {
+			visit(goal, straightCost)
+		}
+	}
+	return nil
+}
+
+// estimate is the octile distance between two cells, which never
+// overestimates the cost with these steps.
+func (g *navGrid) estimate(a, b int) int {
+	dx := a%g.cols - b%g.cols
+	dy := a/g.cols - b/g.cols
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	return straightCost*max(dx, dy) + (diagonalCost-straightCost)*min(dx, dy)
+}
+
+func (g *navGrid) adjacent(a, b int) bool {
+	dx := a%g.cols - b%g.cols
+	dy := a/g.cols - b/g.cols
+	return dx >= -1 && dx <= 1 && dy >= -1 && dy <= 1
+}
+
+func (g *navGrid) walkBack(cameFrom map[int]int, start, goal int) []Vector2 {
+	var cells []int
+	for cell := goal; cell != start; cell = cameFrom[cell] {
+		cells = append(cells, cell)
+	}
+	path := make([]Vector2, len(cells))
+	for i, cell := range cells {
+		path[len(cells)-1-i] = g.center(cell)
+	}
+	return path
+}
+
+// flowField points every cell of a grid one step along the cheapest way to
+// a goal, so any number of enemies can share one search.
+type flowField struct {
+	grid *navGrid
+	goal int
+	// next is the cell to head for from each cell, -1 where the goal
+	// cannot be reached.
+	next []int
+}
+
+// newFlowField searches outwards from the cell to is in with Dijkstra. Like
+// findPath, the goal cell may be blocked.
+func newFlowField(g *navGrid, to Vector2) *flowField {
+	f := &flowField{grid: g, goal: g.cellAt(to), next: make([]int, len(g.blocked))}
+	cost := make([]int, len(g.blocked))
+	for i := range f.next {
+		f.next[i] = -1
+		cost[i] = -1
+	}
+	cost[f.goal] = 0
+	f.next[f.goal] = f.goal
+	open := &navQueue{{cell: f.goal}}
+	for open.Len() > 0 {
+		current := heap.Pop(open).(navNode)
+		if current.priority > cost[current.cell] {
+			continue
+		}
+		g.neighbors(current.cell, func(j, step int) {
+			next := current.priority + step
+			if cost[j] >= 0 && cost[j] <= next {
+				return
+			}
+			cost[j] = next
+			// the way back to the goal is the way this search came
+			f.next[j] = current.cell
+			heap.Push(open, navNode{cell: j, priority: next})
+		})
+	}
+	// an enemy pushed into the clearance around an obstacle steps out to
+	// the cheapest walkable cell next to it
+	for i, blocked := range g.blocked {
+		if !blocked || f.next[i] >= 0 {
+			continue
+		}
+		x, y := i%g.cols, i/g.cols
+		best := -1
+		for _, n := range neighborOffsets {
+			nx, ny := x+n.dx, y+n.dy
+			if nx < 0 || ny < 0 || nx >= g.cols || ny >= g.rows {
+				continue
+			}
+			j := ny*g.cols + nx
+			if cost[j] >= 0 && !g.blocked[j] && (best < 0 || cost[j] < cost[best]) {
+				best = j
+			}
+		}
+		f.next[i] = best
+	}
+	return f
+}
+
+// waypoint returns where to head from p to follow the field, and false in
+// the goal cell or where the goal cannot be reached.
+func (f *flowField) waypoint(p Vector2) (Vector2, bool) {
+	cell := f.grid.cellAt(p)
+	next := f.next[cell]
+	if next < 0 || cell == f.goal {
+		return Vector2{}, false
+	}
+	return f.grid.center(next), true
+}
+
+type navNode struct {
+	cell     int
+	priority int
+}
+
+// navQueue is a min-heap of cells by priority, ties broken by cell index so
+// searches are deterministic.
+type navQueue []navNode
+
+func (q navQueue) Len() int { return len(q) }
+func (q navQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority < q[j].priority
+	}
+	return q[i].cell < q[j].cell
+}
+func (q navQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *navQueue) Push(x any)   { *q = append(*q, x.(navNode)) }
+func (q *navQueue) Pop() any {
+	old := *q
+	node := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return node
+}
+
+// buildNav cuts the arena into a navigation grid around the obstacles of
+// the stage. Stages without obstacles have no grid, everyone walks
+// straight.
+func (w *World) buildNav() {
+	w.nav = nil
+	w.flow = nil
+	if len(w.obstacles) == 0 {
+		return
+	}
+	rects := make([]Rectangle, len(w.obstacles))
+	for i, o := range w.obstacles {
+		rects[i] = o.Rect
+	}
+	w.nav = newNavGrid(w.Width, w.Height, navCellSize, rects, navClearance)
+}
+
+// playerFlow returns the flow field towards the player, rebuilt at most
+// every flowRefresh and only once the player is in another cell.
+func (w *World) playerFlow() *flowField {
+	player := w.player.Shape().Centroid()
+	if w.flow == nil || (w.clock-w.flowBuilt >= flowRefresh && w.nav.cellAt(player) != w.flow.goal) {
+		w.flow = newFlowField(w.nav, player)
+		w.flowBuilt = w.clock
+	}
+	return w.flow
+}
+
+// towardsPlayer is the velocity that takes the enemy to the player: straight
+// while it can see the player, else along the shared flow field.
+func (e *Enemy) towardsPlayer(w *World, speed float32) Vector2 {
+	player := w.player.Shape().Centroid()
+	center := e.Shape().Centroid()
+	if w.nav == nil || w.lineOfSight(center, player) {
+		return e.velocityTowards(player, speed)
+	}
+	if waypoint, ok := w.playerFlow().waypoint(center); ok {
+		return e.velocityTowards(waypoint, speed)
+	}
+	return e.velocityTowards(player, speed)
+}
+
+// towards is the velocity that takes the enemy to target: straight while
+// nothing is in the way, else along its own A* path, searched again at most
+// every pathRefresh.
+func (e *Enemy) towards(w *World, target Vector2, speed float32) Vector2 {
+	center := e.Shape().Centroid()
+	if w.nav == nil || w.lineOfSight(center, target) {
+		e.path = nil
+		return e.velocityTowards(target, speed)
+	}
+	if w.clock >= e.replanAt {
+		e.path = w.nav.findPath(center, target)
+		e.replanAt = w.clock + pathRefresh
+	}
+	// waypoints count as reached within half a cell
+	for len(e.path) > 0 && e.distanceTo(e.path[0]) < navCellSize/2 {
+		e.path = e.path[1:]
+	}
+	if len(e.path) == 0 {
+		return e.velocityTowards(target, speed)
+	}
+	return e.velocityTowards(e.path[0], speed)
+}
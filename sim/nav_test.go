@@ -0,0 +1,169 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+)
+
+// testCell is the cell size of the grids the tests draw.
+const testCell = 10
+
+// parseNav builds a grid from a map with one character per cell: '#' is an
+// obstacle, 'S' and 'G' are walkable cells the tests start and end in.
+func parseNav(t *testing.T, rows ...string) (g *navGrid, start, goal Vector2) {
+	t.Helper()
+	var obstacles []Rectangle
+	for y, row := range rows {
+		for x, c := range row {
+			p := Vector2{X: (float32(x) + 0.5) * testCell, Y: (float32(y) + 0.5) * testCell}
+			switch c {
+			case '#':
+				obstacles = append(obstacles, Rectangle{X: float32(x) * testCell, Y: float32(y) * testCell, Width: testCell, Height: testCell})
+			case 'S':
+				start = p
+			case 'G':
+				goal = p
+			}
+		}
+	}
+	g = newNavGrid(float32(len(rows[0]))*testCell, float32(len(rows))*testCell, testCell, obstacles, 0)
+	return g, start, goal
+}
+
+// checkSteps fails if the path from start leaves the grid's rules: every
+// step goes to a neighbor, only the goal may be blocked, and diagonal steps
+// do not cut a blocked corner.
+func checkSteps(t *testing.T, g *navGrid, start, goal Vector2, path []Vector2) {
+	t.Helper()
+	if len(path) == 0 {
+		t.Fatal("no path")
+	}
+	if last := g.cellAt(path[len(path)-1]); last != g.cellAt(goal) {
+		t.Fatalf("path ends in cell %d, want the goal cell %d", last, g.cellAt(goal))
+	}
+	from := g.cellAt(start)
+	for i, p := range path {
+		to := g.cellAt(p)
+		if !g.adjacent(from, to) || from == to {
+			t.Fatalf("step %d goes from cell %d to %d, which are not neighbors", i, from, to)
+		}
+		if g.blocked[to] && to != g.cellAt(goal) {
+			t.Fatalf("step %d enters blocked cell %d", i, to)
+		}
+		fx, fy := from%g.cols, from/g.cols
+		tx, ty := to%g.cols, to/g.cols
+		if fx != tx && fy != ty && (g.blocked[fy*g.cols+tx] || g.blocked[ty*g.cols+fx]) {
+			t.Fatalf("step %d cuts a blocked corner from cell %d to %d", i, from, to)
+		}
+		from = to
+	}
+}
+
+func TestFindPathAroundWall(t *testing.T) {
+	g, start, goal := parseNav(t,
+		"..........",
+		"....#.....",
+		"S...#...G.",
+		"....#.....",
+		"....#.....",
+		"..........",
+	)
+	path := g.findPath(start, goal)
+	checkSteps(t, g, start, goal, path)
+}
+
+func TestFindPathDoesNotCutCorners(t *testing.T) {
+	g, start, goal := parseNav(t,
+		"S#",
+		".G",
+	)
+	path := g.findPath(start, goal)
+	checkSteps(t, g, start, goal, path)
+	if len(path) != 2 {
+		t.Fatalf("path %v, want the two straight steps around the corner", path)
+	}
+}
+
+func TestFindPathEnclosedGoal(t *testing.T) {
+	g, start, goal := parseNav(t,
+		"S.......",
+		"....###.",
+		"....#G#.",
+		"....###.",
+	)
+	if path := g.findPath(start, goal); path != nil {
+		t.Fatalf("path %v to an enclosed goal, want nil", path)
+	}
+}
+
+func TestFindPathGivesUpAtNodeLimit(t *testing.T) {
+	// a wall down the middle with a gap only at the far end makes A* search
+	// most of the left half, which is more cells than it may expand
+	const side = 100
+	rows := make([]string, side)
+	for y := range rows {
+		row := []byte(strings.Repeat(".", side))
+		if y < side-1 {
+			row[side/2] = '#'
+		}
+		rows[y] = string(row)
+	}
+	rows[0] = rows[0][:side/2-1] + "S#G" + rows[0][side/2+2:]
+	g, start, goal := parseNav(t, rows...)
+	if path := g.findPath(start, goal); path != nil {
+		t.Fatalf("path of %d steps, want nil once %d cells are expanded", len(path), maxPathNodes)
+	}
+	// the goal is reachable, the search only gave up
+	if next := newFlowField(g, goal).next[g.cellAt(start)]; next < 0 {
+		t.Fatal("the flow field cannot reach the start either")
+	}
+}
+
+func TestFindPathReachesBlockedGoal(t *testing.T) {
+	g, start, _ := parseNav(t,
+		"S.....",
+		"......",
+		"....#.",
+	)
+	goal := Vector2{X: 4.5 * testCell, Y: 2.5 * testCell}
+	if !g.blocked[g.cellAt(goal)] {
+		t.Fatal("the goal cell should be blocked")
+	}
+	path := g.findPath(start, goal)
+	checkSteps(t, g, start, goal, path)
+}
+
+func TestFlowFieldLeadsToGoal(t *testing.T) {
+	g, _, goal := parseNav(t,
+		"..........",
+		"..####....",
+		"..#..#..#.",
+		"..#.G#..#.",
+		"..##.#..#.",
+		"......###.",
+		"..........",
+	)
+	f := newFlowField(g, goal)
+	for i := range g.blocked {
+		if f.next[i] < 0 {
+			if !g.blocked[i] {
+				t.Errorf("walkable cell %d cannot reach the goal", i)
+			}
+			continue
+		}
+		cell := i
+		for steps := 0; cell != f.goal; steps++ {
+			if steps > len(g.blocked) {
+				t.Fatalf("the chain from cell %d loops", i)
+			}
+			next := f.next[cell]
+			if next < 0 {
+				t.Fatalf("the chain from cell %d dead-ends in cell %d", i, cell)
+			}
+			if !g.adjacent(cell, next) {
+				t.Fatalf("the chain from cell %d jumps from cell %d to %d", i, cell, next)
+			}
+			cell = next
+		}
+	}
+}
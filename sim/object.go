@@ -0,0 +1,18 @@
+package sim
+
+import "time"
+
+type GameObject interface {
+	GameObjectId() int
+	IsEnemy() bool
+	IsBullet() bool
+	// Shape is what the object collides with, in world coordinates.
+	Shape() Shape
+	// SpriteRect is where the front end draws the object's sprite. Shape
+	// is derived from the same position, so the two always line up.
+	SpriteRect() Rectangle
+	Position() Vector2
+	Move(dt time.Duration)
+	PrevPosition() Vector2
+	EnemyPlan(w *World)
+}
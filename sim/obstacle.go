@@ -0,0 +1,130 @@
+package sim
+
+// ObstacleKind decides what an obstacle does to bullets. Every obstacle
+// blocks walking and sight.
+type ObstacleKind struct {
+	Name string
+	// Ricochet bounces bullets off instead of stopping them.
+	Ricochet bool
+}
+
+var (
+	Rock    = ObstacleKind{Name: "rock"}
+	IceWall = ObstacleKind{Name: "ice", Ricochet: true}
+	Tree    = ObstacleKind{Name: "tree"}
+)
+
+// obstacleKinds lists the obstacle kinds a stage file may ask for.
+var obstacleKinds = map[string]*ObstacleKind{
+	Rock.Name:    &Rock,
+	IceWall.Name: &IceWall,
+	Tree.Name:    &Tree,
+}
+
+// Obstacle is a solid rectangle in the arena.
+type Obstacle struct {
+	Kind *ObstacleKind
+	Rect Rectangle
+}
+
+// maxRicochets is how many times a bullet bounces before the next wall
+// stops it.
+const maxRicochets = 2
+
+// Obstacles returns the obstacles of the stage in play.
+func (w *World) Obstacles() []Obstacle {
+	return w.obstacles
+}
+
+// placeObstacles turns the stage's obstacles, given as fractions of the
+// arena, into world rectangles.
+func (w *World) placeObstacles(defs []ObstacleDef) {
+	w.obstacles = w.obstacles[:0]
+	for _, def := range defs {
+		w.obstacles = append(w.obstacles, Obstacle{
+			Kind: obstacleKinds[def.Kind],
+			Rect: Rectangle{
+				X:      def.X * w.Width,
+				Y:      def.Y * w.Height,
+				Width:  def.Width * w.Width,
+				Height: def.Height * w.Height,
+			},
+		})
+	}
+}
+
+// blocked reports whether rect overlaps any obstacle.
+func (w *World) blocked(rect Rectangle) bool {
+	for _, o := range w.obstacles {
+		if CheckCollisionRecs(rect, o.Rect) {
+			return true
+		}
+	}
+	return false
+}
+
+// slide moves a body from from towards to one axis at a time and holds back
+// each axis that would run into an obstacle, so bodies slide along walls
+// instead of sticking to them. body is the collision box relative to the
+// position. A body already stuck inside an obstacle moves freely, so it can
+// get out.
+func (w *World) slide(from, to Vector2, body Rectangle) Vector2 {
+	at := func(p Vector2) Rectangle {
+		return Rectangle{X: p.X + body.X, Y: p.Y + body.Y, Width: body.Width, Height: body.Height}
+	}
+	if len(w.obstacles) == 0 || w.blocked(at(from)) {
+		return to
+	}
+	pos := from
+	pos.X = to.X
+	if w.blocked(at(pos)) {
+		pos.X = from.X
+	}
+	pos.Y = to.Y
+	if w.blocked(at(pos)) {
+		pos.Y = from.Y
+	}
+	return pos
+}
+
+// relativeBody is obj's collision box relative to its position, for slide.
+func relativeBody(obj GameObject) Rectangle {
+	bounds := obj.Shape().Bounds()
+	position := obj.Position()
+	bounds.X -= position.X
+	bounds.Y -= position.Y
+	return bounds
+}
+
+// obstacleHit finds the first obstacle a circle of radius moving from p to
+// q runs into. It returns how far along the way, from 0 to 1, it hits and
+// the normal of the side it hits.
+func (w *World) obstacleHit(p, q Vector2, radius float32) (hit *Obstacle, t float32, normal Vector2) {
+	t = 2
+	for i := range w.obstacles {
+		grown := w.obstacles[i].Rect
+		grown.X -= radius
+		grown.Y -= radius
+		grown.Width += radius * 2
+		grown.Height += radius * 2
+		if !lineIntersectsRect(p, q, grown) {
+			continue
+		}
+		entry, side, ok := segmentEntry(p, q, grown)
+		if ok && entry < t {
+			hit, t, normal = &w.obstacles[i], entry, side
+		}
+	}
+	return hit, t, normal
+}
+
+// lineOfSight reports whether no obstacle stands on the straight line from
+// from to to.
+func (w *World) lineOfSight(from, to Vector2) bool {
+	for _, o := range w.obstacles {
+		if lineIntersectsRect(from, to, o.Rect) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package sim
+
+import "time"
+
+// playerBody is the hero's body inside the 100x100 sprite, ignoring the
+// empty margin around it.
+var playerBody = Rectangle{X: 20, Y: 18, Width: 40, Height: 58}
+
+type Player struct {
+	id int
+	// sourceRec is the sprite size. The sprite is drawn up and left of
+	// position by a third of its size.
+	sourceRec    Rectangle
+	position     Vector2
+	prevPosition Vector2
+	// units per second
+	movementSpeed float32
+	// 0: front 1: right 2: back 3: left
+	movement int
+	hp       int
+	// invulnerableLeft counts down after a hit; the player cannot be hurt
+	// until it reaches zero.
+	invulnerableLeft time.Duration
+	// knockback is the velocity, in units per second, of the push from the
+	// last hit. It fades out over a fraction of a second.
+	knockback Vector2
+
+	// weapon is the index into Weapons of the weapon in hand.
+	weapon int
+	// ammo is what is left in the magazine of every weapon in Weapons.
+	ammo []int
+	// cooldownLeft counts down from the weapon's FireInterval after a shot.
+	cooldownLeft time.Duration
+	// reloadLeft counts down while reloading; the magazine is full when it
+	// reaches zero.
+	reloadLeft time.Duration
+	// triggerHeld is whether fire was held on the previous step, so
+	// non-automatic weapons fire once per pull.
+	triggerHeld bool
+
+	// aim is the unit vector from the player's body to where it last aimed.
+	aim Vector2
+	// dashLeft counts down while dashing along dashDirection.
+	dashLeft         time.Duration
+	dashDirection    Vector2
+	dashCooldownLeft time.Duration
+}
+
+func (p *Player) GameObjectId() int {
+	return p.id
+}
+
+func (p *Player) IsEnemy() bool {
+	return false
+}
+
+func (p *Player) IsBullet() bool {
+	return false
+}
+
+func (p *Player) SpriteRect() Rectangle {
+	return Rectangle{
+		X:      p.position.X - p.sourceRec.Width/3,
+		Y:      p.position.Y - p.sourceRec.Height/3,
+		Width:  p.sourceRec.Width,
+		Height: p.sourceRec.Height,
+	}
+}
+
+func (p *Player) Shape() Shape {
+	sprite := p.SpriteRect()
+	return RectShape(Rectangle{
+		X:      sprite.X + playerBody.X,
+		Y:      sprite.Y + playerBody.Y,
+		Width:  playerBody.Width,
+		Height: playerBody.Height,
+	})
+}
+
+func (p *Player) Position() Vector2 {
+	return p.position
+}
+
+func (p *Player) Move(dt time.Duration) {
+}
+
+func (p *Player) EnemyPlan(w *World) {
+}
+
+func (p *Player) PrevPosition() Vector2 {
+	return p.prevPosition
+}
+
+// teleport moves the player without leaving a trail for interpolation.
+func (p *Player) teleport(position Vector2) {
+	p.position = position
+	p.prevPosition = position
+	p.knockback = Vector2{}
+	p.invulnerableLeft = 0
+	p.dashLeft = 0
+	p.dashCooldownLeft = 0
+}
+
+// rearm fills every magazine and puts the first weapon in hand.
+func (p *Player) rearm() {
+	p.weapon = 0
+	p.ammo = make([]int, len(Weapons))
+	for i, weapon := range Weapons {
+		p.ammo[i] = weapon.Magazine
+	}
+	p.cooldownLeft = 0
+	p.reloadLeft = 0
+	p.triggerHeld = false
+}
+
+func (p *Player) HP() int {
+	return p.hp
+}
+
+// IsInvulnerable reports whether the player is in the grace period after a
+// hit.
+func (p *Player) IsInvulnerable() bool {
+	return p.invulnerableLeft > 0
+}
+
+// InvulnerableLeft is how much of the grace period is left, for blinking.
+func (p *Player) InvulnerableLeft() time.Duration {
+	return p.invulnerableLeft
+}
+
+func (p *Player) Movement() int {
+	return p.movement
+}
+
+// Aim is the unit vector from the player's body to where it last aimed,
+// zero before it ever aimed.
+func (p *Player) Aim() Vector2 {
+	return p.aim
+}
+
+// IsDashing reports whether the player is in the middle of a dash.
+func (p *Player) IsDashing() bool {
+	return p.dashLeft > 0
+}
+
+// DashCooldownLeft is how long until the player can dash again.
+func (p *Player) DashCooldownLeft() time.Duration {
+	return p.dashCooldownLeft
+}
+
+func (p *Player) Weapon() Weapon {
+	return Weapons[p.weapon]
+}
+
+// WeaponSlot is the 1-based slot of the weapon in hand, as used by
+// Input.Weapon.
+func (p *Player) WeaponSlot() int {
+	return p.weapon + 1
+}
+
+// Ammo is what is left in the magazine of the weapon in hand.
+func (p *Player) Ammo() int {
+	return p.ammo[p.weapon]
+}
+
+// ReloadLeft is how long until the weapon in hand is reloaded, zero when it
+// is not reloading.
+func (p *Player) ReloadLeft() time.Duration {
+	return p.reloadLeft
+}
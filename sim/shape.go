@@ -0,0 +1,98 @@
+package sim
+
+type ShapeKind int
+
+const (
+	ShapeRect ShapeKind = iota
+	ShapeCircle
+)
+
+// Shape is the collision area of a game object in world coordinates. It is
+// an axis aligned box or a circle.
+type Shape struct {
+	Kind ShapeKind
+	// Rect is set for ShapeRect.
+	Rect Rectangle
+	// Center and Radius are set for ShapeCircle.
+	Center Vector2
+	Radius float32
+}
+
+func RectShape(rect Rectangle) Shape {
+	return Shape{Kind: ShapeRect, Rect: rect}
+}
+
+func CircleShape(center Vector2, radius float32) Shape {
+	return Shape{Kind: ShapeCircle, Center: center, Radius: radius}
+}
+
+// Bounds is the smallest rectangle around the shape.
+func (s Shape) Bounds() Rectangle {
+	if s.Kind == ShapeCircle {
+		return Rectangle{
+			X:      s.Center.X - s.Radius,
+			Y:      s.Center.Y - s.Radius,
+			Width:  s.Radius * 2,
+			Height: s.Radius * 2,
+		}
+	}
+	return s.Rect
+}
+
+func (s Shape) Centroid() Vector2 {
+	if s.Kind == ShapeCircle {
+		return s.Center
+	}
+	return Vector2{X: s.Rect.X + s.Rect.Width/2, Y: s.Rect.Y + s.Rect.Height/2}
+}
+
+func (s Shape) Overlaps(other Shape) bool {
+	switch {
+	case s.Kind == ShapeRect && other.Kind == ShapeRect:
+		return CheckCollisionRecs(s.Rect, other.Rect)
+	case s.Kind == ShapeCircle && other.Kind == ShapeCircle:
+		d := Vector2{X: s.Center.X - other.Center.X, Y: s.Center.Y - other.Center.Y}
+		r := s.Radius + other.Radius
+		return d.X*d.X+d.Y*d.Y <= r*r
+	case s.Kind == ShapeCircle:
+		return circleOverlapsRect(s.Center, s.Radius, other.Rect)
+	default:
+		return circleOverlapsRect(other.Center, other.Radius, s.Rect)
+	}
+}
+
+// SweptOverlaps reports whether a circle of radius moving from p to q
+// touches the shape anywhere along the way, so fast bullets cannot tunnel
+// through thin targets.
+func (s Shape) SweptOverlaps(p, q Vector2, radius float32) bool {
+	if s.Kind == ShapeCircle {
+		return distanceToSegment(s.Center, p, q) <= s.Radius+radius
+	}
+	grown := Rectangle{
+		X:      s.Rect.X - radius,
+		Y:      s.Rect.Y - radius,
+		Width:  s.Rect.Width + radius*2,
+		Height: s.Rect.Height + radius*2,
+	}
+	return lineIntersectsRect(p, q, grown)
+}
+
+func circleOverlapsRect(center Vector2, radius float32, rect Rectangle) bool {
+	closest := Vector2{
+		X: clamp(center.X, rect.X, rect.X+rect.Width),
+		Y: clamp(center.Y, rect.Y, rect.Y+rect.Height),
+	}
+	d := Vector2{X: center.X - closest.X, Y: center.Y - closest.Y}
+	return d.X*d.X+d.Y*d.Y <= radius*radius
+}
+
+func distanceToSegment(point, p, q Vector2) float32 {
+	segment := Vector2{X: q.X - p.X, Y: q.Y - p.Y}
+	lengthSquared := segment.X*segment.X + segment.Y*segment.Y
+	t := float32(0)
+	if lengthSquared != 0 {
+		t = clamp(((point.X-p.X)*segment.X+(point.Y-p.Y)*segment.Y)/lengthSquared, 0, 1)
+	}
+	closest := Vector2{X: p.X + segment.X*t, Y: p.Y + segment.Y*t}
+	return length(Vector2{X: point.X - closest.X, Y: point.Y - closest.Y})
+}
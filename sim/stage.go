@@ -0,0 +1,276 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Spawn rules for an enemy group.
+const (
+	// SpawnRandom places enemies anywhere in the arena at least MinDistance
+	// away from the player.
+	SpawnRandom = "random"
+	// SpawnFixed places enemies at Positions, given as fractions of the arena
+	// size so a stage looks the same on every screen. An enemy that would
+	// land in an obstacle or closer than MinDistance to the player spawns
+	// like SpawnRandom instead.
+	SpawnFixed = "fixed"
+	// SpawnEdge places enemies along the edges of the arena at least
+	// MinDistance away from the player.
+	SpawnEdge = "edge"
+)
+
+const defaultMinDistance float32 = 1000
+
+// defaultFixedMinDistance is how close to the player a fixed position may
+// put an enemy when its group sets no MinDistance. It is lower than
+// defaultMinDistance so stages can place enemies in view.
+const defaultFixedMinDistance float32 = 300
+
+// StageDef describes one stage of the campaign. It is loaded from a JSON file
+// so designers can tune stages without recompiling.
+type StageDef struct {
+	// Name is what the stage is called on screen and in the records, so
+	// every stage needs its own.
+	Name       string `json:"name"`
+	Background string `json:"background"`
+	// TimeLimit is in seconds, 0 means no limit.
+	TimeLimit float64      `json:"timeLimit"`
+	Enemies   []EnemyGroup `json:"enemies"`
+	// Obstacles are optional.
+	Obstacles []ObstacleDef `json:"obstacles"`
+}
+
+// ObstacleDef is one obstacle of a stage. Like fixed spawn positions, the
+// rectangle is given in fractions of the arena size.
+type ObstacleDef struct {
+	Kind   string  `json:"kind"`
+	X      float32 `json:"x"`
+	Y      float32 `json:"y"`
+	Width  float32 `json:"width"`
+	Height float32 `json:"height"`
+}
+
+// spawnArea is the middle of the arena, in fractions, where the player
+// starts every stage. Obstacles must leave it free.
+var spawnArea = Rectangle{X: 0.45, Y: 0.45, Width: 0.1, Height: 0.1}
+
+// EnemyGroup is a number of enemies of one type that spawn the same way.
+// A group without After or WhenFewerThan is there when the stage starts,
+// the others are waves that join in while it is played.
+type EnemyGroup struct {
+	Type        string    `json:"type"`
+	Count       int       `json:"count"`
+	Spawn       string    `json:"spawn"`
+	MinDistance *float32  `json:"minDistance"`
+	Positions   []Vector2 `json:"positions"`
+	// After is how many seconds into the stage the wave comes at the
+	// earliest.
+	After float64 `json:"after"`
+	// WhenFewerThan holds the wave back until fewer enemies than this are
+	// left.
+	WhenFewerThan *int `json:"whenFewerThan"`
+}
+
+// isWave reports whether the group spawns while the stage is played
+// instead of at its start.
+func (g EnemyGroup) isWave() bool {
+	return g.After > 0 || g.WhenFewerThan != nil
+}
+
+func (g EnemyGroup) after() time.Duration {
+	return time.Duration(g.After * float64(time.Second))
+}
+
+func (s StageDef) timeLimit() time.Duration {
+	return time.Duration(s.TimeLimit * float64(time.Second))
+}
+
+// Validate reports the first problem with the stage, if any.
+func (s StageDef) Validate() error {
+	if s.Name == "" {
+		return errors.New("name is missing")
+	}
+	if s.Background == "" {
+		return errors.New("background is missing")
+	}
+	if s.TimeLimit < 0 {
+		return fmt.Errorf("timeLimit must not be negative, got %v", s.TimeLimit)
+	}
+	if len(s.Enemies) == 0 {
+		return errors.New("enemies is empty, the stage would be won at once")
+	}
+	for i, group := range s.Enemies {
+		if err := group.validate(); err != nil {
+			return fmt.Errorf("enemies[%d]: %w", i, err)
+		}
+	}
+	for i, obstacle := range s.Obstacles {
+		if err := obstacle.validate(); err != nil {
+			return fmt.Errorf("obstacles[%d]: %w", i, err)
+		}
+	}
+	for i, group := range s.Enemies {
+		for j, p := range group.Positions {
+			if err := s.validatePosition(p); err != nil {
+				return fmt.Errorf("enemies[%d]: positions[%d]: %w", i, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validatePosition checks that a fixed spawn position is neither where the
+// player starts nor inside an obstacle. It only looks at the corner the
+// position gives, spawnGroup moves an enemy whose body still overlaps.
+func (s StageDef) validatePosition(p Vector2) error {
+	if pointInRect(p, spawnArea) {
+		return fmt.Errorf("%v,%v is in the middle of the arena, where the player starts", p.X, p.Y)
+	}
+	for i, o := range s.Obstacles {
+		if pointInRect(p, Rectangle{X: o.X, Y: o.Y, Width: o.Width, Height: o.Height}) {
+			return fmt.Errorf("%v,%v is inside obstacles[%d]", p.X, p.Y, i)
+		}
+	}
+	return nil
+}
+
+func (o ObstacleDef) validate() error {
+	if _, ok := obstacleKinds[o.Kind]; !ok {
+		return fmt.Errorf("unknown kind %q, known kinds are %s", o.Kind, knownObstacleKinds())
+	}
+	if o.Width <= 0 || o.Height <= 0 {
+		return fmt.Errorf("width and height must be positive, got %v,%v", o.Width, o.Height)
+	}
+	if o.X < 0 || o.Y < 0 || o.X+o.Width > 1 || o.Y+o.Height > 1 {
+		return fmt.Errorf("%v,%v %vx%v reaches outside the arena, use fractions between 0 and 1", o.X, o.Y, o.Width, o.Height)
+	}
+	if CheckCollisionRecs(Rectangle{X: o.X, Y: o.Y, Width: o.Width, Height: o.Height}, spawnArea) {
+		return errors.New("covers the middle of the arena, where the player starts")
+	}
+	return nil
+}
+
+func (g EnemyGroup) validate() error {
+	if _, ok := enemyTypes[g.Type]; !ok {
+		return fmt.Errorf("unknown type %q, known types are %s", g.Type, knownEnemyTypes())
+	}
+	if g.Count <= 0 {
+		return fmt.Errorf("count must be at least 1, got %d", g.Count)
+	}
+	if g.After < 0 {
+		return fmt.Errorf("after must not be negative, got %v", g.After)
+	}
+	if g.WhenFewerThan != nil && *g.WhenFewerThan < 1 {
+		return fmt.Errorf("whenFewerThan must be at least 1, got %d", *g.WhenFewerThan)
+	}
+	if g.MinDistance != nil && *g.MinDistance < 0 {
+		return fmt.Errorf("minDistance must not be negative, got %v", *g.MinDistance)
+	}
+	switch g.Spawn {
+	case SpawnRandom, SpawnEdge:
+		if len(g.Positions) != 0 {
+			return fmt.Errorf("positions are only used with spawn %q", SpawnFixed)
+		}
+	case SpawnFixed:
+		if len(g.Positions) != g.Count {
+			return fmt.Errorf("spawn %q needs one position per enemy, got %d positions for count %d", SpawnFixed, len(g.Positions), g.Count)
+		}
+		for i, p := range g.Positions {
+			if p.X < 0 || p.X > 1 || p.Y < 0 || p.Y > 1 {
+				return fmt.Errorf("positions[%d] is %v,%v but must be fractions of the arena between 0 and 1", i, p.X, p.Y)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown spawn %q, use %q, %q or %q", g.Spawn, SpawnRandom, SpawnFixed, SpawnEdge)
+	}
+	return nil
+}
+
+func knownEnemyTypes() string {
+	names := make([]string, 0, len(enemyTypes))
+	for name := range enemyTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func knownObstacleKinds() string {
+	names := make([]string, 0, len(obstacleKinds))
+	for name := range obstacleKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// ParseStage decodes and validates one stage file. name is only used in
+// error messages.
+func ParseStage(name string, data []byte) (StageDef, error) {
+	var stage StageDef
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&stage); err != nil {
+		return StageDef{}, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := stage.Validate(); err != nil {
+		return StageDef{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return stage, nil
+}
+
+// LoadStages reads every *.json file at the root of each file system and
+// returns the stages ordered by file name. A file in a later file system
+// replaces the file of the same name in an earlier one, so an on-disk
+// directory can override single stages of the embedded campaign. Two stages
+// must not share a name.
+func LoadStages(fileSystems ...fs.FS) ([]StageDef, error) {
+	files := make(map[string][]byte)
+	for _, fsys := range fileSystems {
+		entries, err := fs.ReadDir(fsys, ".")
+		if err != nil {
+			return nil, fmt.Errorf("reading stage directory: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+				continue
+			}
+			data, err := fs.ReadFile(fsys, entry.Name())
+			if err != nil {
+				return nil, fmt.Errorf("reading stage file: %w", err)
+			}
+			files[entry.Name()] = data
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no stage files found")
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	stages := make([]StageDef, 0, len(names))
+	fileOf := make(map[string]string, len(names))
+	for _, name := range names {
+		stage, err := ParseStage(name, files[name])
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := fileOf[stage.Name]; ok {
+			return nil, fmt.Errorf("%s: name %q is already used by %s", name, stage.Name, other)
+		}
+		fileOf[stage.Name] = name
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
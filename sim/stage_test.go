@@ -0,0 +1,119 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseStage(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		// err is part of the error, empty if the stage is valid
+		err string
+	}{
+		{
+			name: "valid",
+			data: `{"name": "1", "background": "snow.png", "enemies": [{"type": "enemy", "count": 2, "spawn": "random"}]}`,
+		},
+		{
+			name: "no name",
+			data: `{"background": "snow.png", "enemies": [{"type": "enemy", "count": 1, "spawn": "random"}]}`,
+			err:  "name is missing",
+		},
+		{
+			name: "bad type",
+			data: `{"name": "1", "background": "snow.png", "enemies": [{"type": "dragon", "count": 1, "spawn": "random"}]}`,
+			err:  `enemies[0]: unknown type "dragon"`,
+		},
+		{
+			name: "bad spawn",
+			data: `{"name": "1", "background": "snow.png", "enemies": [{"type": "enemy", "count": 1, "spawn": "sky"}]}`,
+			err:  `enemies[0]: unknown spawn "sky"`,
+		},
+		{
+			name: "wrong position count",
+			data: `{"name": "1", "background": "snow.png", "enemies": [
+				{"type": "enemy", "count": 2, "spawn": "fixed", "positions": [{"x": 0.1, "y": 0.1}]}
+			]}`,
+			err: "got 1 positions for count 2",
+		},
+		{
+			name: "unknown field",
+			data: `{"name": "1", "background": "snow.png", "music": "loud.mp3", "enemies": [{"type": "enemy", "count": 1, "spawn": "random"}]}`,
+			err:  `unknown field "music"`,
+		},
+		{
+			name: "obstacle over the spawn area",
+			data: `{"name": "1", "background": "snow.png", "enemies": [{"type": "enemy", "count": 1, "spawn": "random"}],
+				"obstacles": [{"kind": "rock", "x": 0.4, "y": 0.4, "width": 0.1, "height": 0.1}]}`,
+			err: "obstacles[0]: covers the middle of the arena",
+		},
+		{
+			name: "fixed position on the player",
+			data: `{"name": "1", "background": "snow.png", "enemies": [
+				{"type": "enemy", "count": 1, "spawn": "fixed", "positions": [{"x": 0.5, "y": 0.5}]}
+			]}`,
+			err: "enemies[0]: positions[0]: 0.5,0.5 is in the middle of the arena",
+		},
+		{
+			name: "fixed position in an obstacle",
+			data: `{"name": "1", "background": "snow.png", "enemies": [
+				{"type": "enemy", "count": 2, "spawn": "fixed", "positions": [{"x": 0.1, "y": 0.1}, {"x": 0.25, "y": 0.25}]}
+			], "obstacles": [{"kind": "rock", "x": 0.2, "y": 0.2, "width": 0.1, "height": 0.1}]}`,
+			err: "enemies[0]: positions[1]: 0.25,0.25 is inside obstacles[0]",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseStage("stage.json", []byte(tc.data))
+			switch {
+			case tc.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.err != "" && err == nil:
+				t.Fatalf("no error, want one with %q", tc.err)
+			case tc.err != "" && !strings.Contains(err.Error(), tc.err):
+				t.Fatalf("error %q, want one with %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func stageFile(name, background string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(`{"name": "` + name + `", "background": "` + background + `",
+		"enemies": [{"type": "enemy", "count": 1, "spawn": "random"}]}`)}
+}
+
+func TestLoadStagesOverride(t *testing.T) {
+	embedded := fstest.MapFS{
+		"stage01.json": stageFile("1", "snow.png"),
+		"stage02.json": stageFile("2", "snow.png"),
+		"notes.txt":    {Data: []byte("not a stage")},
+	}
+	override := fstest.MapFS{
+		"stage02.json": stageFile("2", "ice.png"),
+		"stage03.json": stageFile("3", "snow.png"),
+	}
+	stages, err := LoadStages(embedded, override)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range stages {
+		got = append(got, s.Name+":"+s.Background)
+	}
+	want := "1:snow.png 2:ice.png 3:snow.png"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("stages %v, want %s", got, want)
+	}
+}
+
+func TestLoadStagesDuplicateName(t *testing.T) {
+	_, err := LoadStages(fstest.MapFS{
+		"stage01.json": stageFile("1", "snow.png"),
+		"stage02.json": stageFile("1", "ice.png"),
+	})
+	if err == nil || !strings.Contains(err.Error(), `stage02.json: name "1" is already used by stage01.json`) {
+		t.Fatalf("error %v, want the second stage named 1 rejected", err)
+	}
+}
@@ -0,0 +1,90 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Weapon decides what one pull of the trigger fires.
+type Weapon struct {
+	Name string
+	// Pellets is how many bullets one shot fires.
+	Pellets int
+	// Spread is the cone, in degrees, the pellets fan out over. A single
+	// pellet lands anywhere in the cone instead.
+	Spread float32
+	// FireInterval is the shortest time between two shots.
+	FireInterval time.Duration
+	// ProjectileSpeed is in units per second.
+	ProjectileSpeed float32
+	// Damage is what one pellet takes off an enemy's HP.
+	Damage int
+	// Knockback is the speed, in units per second, one pellet pushes an
+	// enemy along its flight with.
+	Knockback float32
+	Magazine  int
+	Reload    time.Duration
+	// Automatic weapons keep firing while the trigger is held, the others
+	// fire once per pull.
+	Automatic bool
+}
+
+var (
+	Pistol = Weapon{
+		Name:            "Pistol",
+		Pellets:         1,
+		FireInterval:    time.Duration(200) * time.Millisecond,
+		ProjectileSpeed: 6000,
+		Damage:          3,
+		Knockback:       600,
+		Magazine:        12,
+		Reload:          time.Duration(1000) * time.Millisecond,
+	}
+	Shotgun = Weapon{
+		Name:            "Shotgun",
+		Pellets:         7,
+		Spread:          30,
+		FireInterval:    time.Duration(700) * time.Millisecond,
+		ProjectileSpeed: 4500,
+		Damage:          1,
+		Knockback:       300,
+		Magazine:        6,
+		Reload:          time.Duration(1800) * time.Millisecond,
+	}
+	SMG = Weapon{
+		Name:            "SMG",
+		Pellets:         1,
+		Spread:          10,
+		FireInterval:    time.Duration(80) * time.Millisecond,
+		ProjectileSpeed: 5000,
+		Damage:          1,
+		Knockback:       200,
+		Magazine:        30,
+		Reload:          time.Duration(1500) * time.Millisecond,
+		Automatic:       true,
+	}
+)
+
+// Weapons is what the player carries, in slot order. Slot 1 is the first
+// weapon.
+var Weapons = []Weapon{Pistol, Shotgun, SMG}
+
+// pelletAngles returns the direction, in radians, of every pellet of one
+// shot aimed at angle.
+func (w Weapon) pelletAngles(angle float64, rng *rand.Rand) []float64 {
+	spread := float64(w.Spread) * math.Pi / 180
+	angles := make([]float64, w.Pellets)
+	if w.Pellets == 1 {
+		if spread == 0 {
+			angles[0] = angle
+			return angles
+		}
+		angles[0] = angle + (rng.Float64()-0.5)*spread
+		return angles
+	}
+	for i := range angles {
+		angles[i] = angle - spread/2 + spread*float64(i)/float64(w.Pellets-1)
+	}
+	return angles
+}
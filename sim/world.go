@@ -0,0 +1,1023 @@
+// Package sim is the headless game core. It owns the world state and advances
+// it from an explicit Input, so it runs without a window or raylib.
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Version identifies the game rules. Replays recorded with another version
+// may not play back the same way.
+const Version = "1.14.0"
+
+// FixedStep is the length of one simulation step. The front end calls
+// Update once per step no matter how fast it renders.
+const FixedStep = time.Second / 60
+
+// Input is everything the simulation reads from the player in one step.
+// Aim is in arena coordinates.
+type Input struct {
+	Up    bool
+	Left  bool
+	Down  bool
+	Right bool
+	// Move is the push of a stick, each axis between -1 and 1. It only
+	// moves the player while no direction above is held.
+	Move Vector2
+	Aim  Vector2
+	// Fire is whether the trigger is held this step.
+	Fire   bool
+	Reload bool
+	// Dash starts a dash if it is not cooling down.
+	Dash bool
+	// Weapon is the 1-based slot of the weapon to switch to, 0 keeps the
+	// one in hand.
+	Weapon int
+	// RestartStage throws away the stage in progress and starts it over.
+	// It is an input, not a method call, so replays see it too.
+	RestartStage bool
+}
+
+// Events reports what happened during one Update so the front end can play
+// sounds and switch screens.
+type Events struct {
+	ShotFired bool
+	// PlayerHit is set when an enemy hurt the player without ending the run.
+	PlayerHit    bool
+	PlayerDied   bool
+	StageCleared bool
+	// TimeUp is set together with PlayerDied when the stage time limit ran
+	// out.
+	TimeUp         bool
+	StageRestarted bool
+	// BossPhase is set when a boss moves on to its next phase.
+	BossPhase bool
+	// BossDefeated is set when a boss's defeat sequence begins. The stage
+	// is cleared once it ends.
+	BossDefeated bool
+}
+
+type World struct {
+	Width            float32
+	Height           float32
+	gameObjects      map[int]GameObject
+	deadObjects      map[int]GameObject
+	nextGameObjectId int
+	nextDeadObjectId int
+	player           *Player
+	stages           []StageDef
+	stageIdx         int
+	stageStartTime   time.Duration
+	difficulty       Difficulty
+	seed             int64
+	rng              *rand.Rand
+	clock            time.Duration
+	// enemyGrid indexes enemies and their shots for the collision checks.
+	// It is rebuilt every step; nil falls back to testing every object.
+	enemyGrid *spatialGrid
+	// obstacles are the rocks, walls and trees of the stage in play.
+	obstacles []Obstacle
+	// nav is the walkable grid around the obstacles, nil when there are
+	// none. flow leads to the player and was built at flowBuilt.
+	nav       *navGrid
+	flow      *flowField
+	flowBuilt time.Duration
+	// waves are the enemy groups of the stage still waiting for their
+	// moment, in stage file order.
+	waves []EnemyGroup
+	mode  Mode
+	// kills counts the enemies the player killed, for the endless score.
+	kills    int
+	director director
+}
+
+// NewWorld creates a world that plays stages. Its randomness is entirely
+// driven by seed, so the same seed and the same input always play out the
+// same way.
+func NewWorld(width, height float32, seed int64, stages []StageDef) *World {
+	w := &World{
+		Width:      width,
+		Height:     height,
+		stages:     stages,
+		difficulty: OneHit,
+		mode:       Campaign,
+		enemyGrid:  newSpatialGrid(enemyCellSize),
+	}
+	w.Reset(seed)
+	return w
+}
+
+// Reset brings the world back to the start of a run played with seed.
+func (w *World) Reset(seed int64) {
+	w.seed = seed
+	w.rng = rand.New(rand.NewSource(seed))
+	w.gameObjects = make(map[int]GameObject)
+	w.deadObjects = make(map[int]GameObject)
+	w.nextDeadObjectId = 0
+	w.stageIdx = 0
+	w.clock = 0
+	w.obstacles = nil
+	w.waves = nil
+	w.kills = 0
+	w.buildNav()
+
+	midPointX, midPointY := w.midPoint(100, 100)
+	w.player = &Player{
+		id:            0,
+		sourceRec:     Rectangle{X: 0, Y: 0, Width: 100, Height: 100},
+		position:      Vector2{X: midPointX, Y: midPointY},
+		prevPosition:  Vector2{X: midPointX, Y: midPointY},
+		movementSpeed: 900,
+		movement:      0,
+		hp:            w.difficulty.MaxHP,
+	}
+	w.player.rearm()
+	w.gameObjects[0] = w.player
+	w.nextGameObjectId = 1
+}
+
+// StartStage puts the player back in the middle, clears the dead bodies and
+// spawns the enemies of stage idx, keeping its waves for later. In endless
+// mode it only sets up the arena for the director. Each stage reseeds the
+// generator from the run seed, so a stage plays the same no matter how the
+// earlier ones went.
+func (w *World) StartStage(idx int) {
+	w.stageIdx = idx
+	w.stageStartTime = w.clock
+	w.rng = rand.New(rand.NewSource(stageSeed(w.seed, idx)))
+	midPointX, midPointY := w.midPoint(100, 100)
+	w.player.teleport(Vector2{X: midPointX, Y: midPointY})
+	w.player.rearm()
+	w.CleanAllDead()
+	w.placeObstacles(w.stages[idx].Obstacles)
+	w.buildNav()
+	w.waves = w.waves[:0]
+	if w.mode == Endless {
+		// the director brings the enemies
+		w.startEndless()
+		return
+	}
+	for _, group := range w.stages[idx].Enemies {
+		if group.isWave() {
+			w.waves = append(w.waves, group)
+			continue
+		}
+		w.spawnGroup(group, Vector2{X: midPointX, Y: midPointY}, 0)
+	}
+}
+
+// spawnIn is how long an enemy of a wave takes to appear. Until then it
+// neither moves, hurts nor gets hurt.
+const spawnIn = time.Duration(800) * time.Millisecond
+
+// spawnWaves spawns every wave whose moment has come. All waves that are
+// due in the same step see the same enemy count.
+func (w *World) spawnWaves() {
+	if len(w.waves) == 0 {
+		return
+	}
+	enemies := 0
+	for _, obj := range w.gameObjects {
+		if obj.IsEnemy() {
+			enemies++
+		}
+	}
+	elapsed := w.clock - w.stageStartTime
+	waiting := w.waves[:0]
+	var due []EnemyGroup
+	for _, wave := range w.waves {
+		if elapsed < wave.after() || (wave.WhenFewerThan != nil && enemies >= *wave.WhenFewerThan) {
+			waiting = append(waiting, wave)
+			continue
+		}
+		due = append(due, wave)
+	}
+	w.waves = waiting
+	for _, wave := range due {
+		w.spawnGroup(wave, w.player.Shape().Centroid(), spawnIn)
+	}
+}
+
+// spawnGroup creates the enemies of one group of the stage file, taking
+// appear to spawn in. Fixed positions are fractions of the room left once
+// the enemy is inside the arena, one that is blocked or too close to the
+// player is given up for a random one.
+func (w *World) spawnGroup(group EnemyGroup, playerCenter Vector2, appear time.Duration) {
+	archetype := enemyTypes[group.Type]
+	size := archetype.Size
+	minDistance := defaultMinDistance
+	if group.Spawn == SpawnFixed {
+		minDistance = defaultFixedMinDistance
+	}
+	if group.MinDistance != nil {
+		minDistance = *group.MinDistance
+	}
+	for i := 0; i < group.Count; i++ {
+		var enemyPosition Vector2
+		if group.Spawn == SpawnFixed {
+			enemyPosition = Vector2{
+				X: group.Positions[i].X * (w.Width - size),
+				Y: group.Positions[i].Y * (w.Height - size),
+			}
+			if distance, clear := w.spawnDistance(enemyPosition, playerCenter, size, size); !clear || distance < minDistance {
+				enemyPosition = w.generateEnemyPosition(playerCenter, size, size, minDistance)
+			}
+		} else if group.Spawn == SpawnEdge {
+			enemyPosition = w.generateEdgePosition(playerCenter, size, size, minDistance)
+		} else {
+			enemyPosition = w.generateEnemyPosition(playerCenter, size, size, minDistance)
+		}
+		w.spawnPack(archetype, enemyPosition, appear)
+	}
+}
+
+// packSpread is how far, in units, the members of a pack spawn from its
+// first member.
+const packSpread = 120
+
+// spawnPack creates archetype.Pack enemies around position that take
+// appear to spawn in. The first one is exactly at position, the rest are
+// scattered around it but stay in the arena.
+func (w *World) spawnPack(archetype *Archetype, position Vector2, appear time.Duration) {
+	w.createEnemy(archetype, position).spawnLeft = appear
+	for i := 1; i < archetype.Pack; i++ {
+		member := Vector2{
+			X: position.X + (w.rng.Float32()*2-1)*packSpread,
+			Y: position.Y + (w.rng.Float32()*2-1)*packSpread,
+		}
+		member.X = clamp(member.X, 0, w.Width-archetype.Size)
+		member.Y = clamp(member.Y, 0, w.Height-archetype.Size)
+		w.createEnemy(archetype, member).spawnLeft = appear
+	}
+}
+
+func (w *World) StageIdx() int {
+	return w.stageIdx
+}
+
+func (w *World) StageCount() int {
+	return len(w.stages)
+}
+
+// Stage returns the definition of the stage being played.
+func (w *World) Stage() StageDef {
+	return w.stages[w.stageIdx]
+}
+
+// TimeLeft returns how long the player has left to clear the stage, and
+// false if the stage has no time limit.
+func (w *World) TimeLeft() (time.Duration, bool) {
+	limit := w.Stage().timeLimit()
+	if limit <= 0 || w.mode == Endless {
+		return 0, false
+	}
+	left := limit - (w.clock - w.stageStartTime)
+	if left < 0 {
+		left = 0
+	}
+	return left, true
+}
+
+// SetDifficulty picks the rule for enemy contact and refills the player's
+// health. Call it before the first stage of a run.
+func (w *World) SetDifficulty(d Difficulty) {
+	w.difficulty = d
+	w.player.hp = d.MaxHP
+}
+
+func (w *World) Difficulty() Difficulty {
+	return w.difficulty
+}
+
+// Elapsed is the simulated time of the run so far. It does not advance while
+// the front end is not stepping the world, such as during a pause.
+func (w *World) Elapsed() time.Duration {
+	return w.clock
+}
+
+// StageElapsed is the simulated time since the stage in play started.
+func (w *World) StageElapsed() time.Duration {
+	return w.clock - w.stageStartTime
+}
+
+func (w *World) Seed() int64 {
+	return w.seed
+}
+
+func stageSeed(seed int64, idx int) int64 {
+	return seed*31 + int64(idx)
+}
+
+func (w *World) IsFinalStage() bool {
+	return w.stageIdx >= len(w.stages)-1
+}
+
+func (w *World) Player() *Player {
+	return w.player
+}
+
+// Objects returns the live game objects, the player, enemies and bullets,
+// in creation order.
+func (w *World) Objects() []GameObject {
+	return orderedObjects(w.gameObjects)
+}
+
+// DeadObjects returns the enemy bodies left on the floor this stage, in
+// creation order.
+func (w *World) DeadObjects() []GameObject {
+	return orderedObjects(w.deadObjects)
+}
+
+// orderedObjects lists objects sorted by id. Go randomizes map iteration,
+// and the simulation must visit objects in the same order on every run.
+func orderedObjects(objects map[int]GameObject) []GameObject {
+	ordered := make([]GameObject, 0, len(objects))
+	for _, obj := range objects {
+		ordered = append(ordered, obj)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].GameObjectId() < ordered[j].GameObjectId()
+	})
+	return ordered
+}
+
+// Update advances the world by one step of length dt, normally FixedStep.
+func (w *World) Update(in Input, dt time.Duration) Events {
+	var events Events
+	if in.RestartStage {
+		w.CleanAllEnemyAndBullet()
+		w.StartStage(w.stageIdx)
+		events.StageRestarted = true
+		return events
+	}
+	w.clock += dt
+
+	w.spawnWaves()
+	w.buryDefeated()
+	if w.hasWonStage() {
+		events.StageCleared = true
+		return events
+	}
+
+	if left, limited := w.TimeLeft(); limited && left <= 0 {
+		events.PlayerDied = true
+		events.TimeUp = true
+		return events
+	}
+
+	w.playerMovement(in, dt)
+	w.indexEnemies()
+	if w.playerDeathCheck(&events) {
+		events.PlayerDied = true
+		return events
+	}
+	if w.mode == Endless {
+		w.direct(&events)
+	}
+
+	w.playerWeapon(in, dt, &events)
+	w.bulletCollisionCheck(&events)
+	w.enemyPlan()
+	w.flock()
+	w.moveGameObjects(dt)
+	return events
+}
+
+func (w *World) midPoint(elementWidth float32, elementHeight float32) (midX float32, midY float32) {
+	return w.Width/2 - elementWidth/2, w.Height/2 - elementHeight/2
+}
+
+// playerWeapon switches, reloads and fires the weapon in the player's hand.
+func (w *World) playerWeapon(in Input, dt time.Duration, events *Events) {
+	player := w.player
+	if in.Weapon > 0 && in.Weapon <= len(Weapons) && in.Weapon-1 != player.weapon {
+		// putting a weapon away abandons its reload
+		player.weapon = in.Weapon - 1
+		player.reloadLeft = 0
+	}
+	weapon := player.Weapon()
+	pulled := in.Fire && !player.triggerHeld
+	player.triggerHeld = in.Fire
+
+	player.cooldownLeft = max(player.cooldownLeft-dt, 0)
+	if player.reloadLeft > 0 {
+		player.reloadLeft -= dt
+		if player.reloadLeft > 0 {
+			return
+		}
+		player.reloadLeft = 0
+		player.ammo[player.weapon] = weapon.Magazine
+	}
+	if in.Reload && player.ammo[player.weapon] < weapon.Magazine {
+		player.reloadLeft = weapon.Reload
+		return
+	}
+
+	if !pulled && !(in.Fire && weapon.Automatic) || player.cooldownLeft > 0 {
+		return
+	}
+	if player.ammo[player.weapon] == 0 {
+		player.reloadLeft = weapon.Reload
+		return
+	}
+	if !w.createBullets(weapon, in.Aim) {
+		return
+	}
+	player.cooldownLeft = weapon.FireInterval
+	player.ammo[player.weapon]--
+	events.ShotFired = true
+	if player.ammo[player.weapon] == 0 {
+		player.reloadLeft = weapon.Reload
+	}
+}
+
+// createBullets fires one shot of weapon from the middle of the player's
+// body towards aim. It reports false, and fires nothing, when aim is that
+// very middle and so has no direction.
+func (w *World) createBullets(weapon Weapon, aim Vector2) bool {
+	origin := w.player.Shape().Centroid()
+	dx := aim.X - origin.X
+	dy := aim.Y - origin.Y
+	if dx == 0 && dy == 0 {
+		return false
+	}
+	angle := math.Atan2(float64(dy), float64(dx))
+	for _, pelletAngle := range weapon.pelletAngles(angle, w.rng) {
+		bulletVector := Vector2{
+			X: float32(math.Cos(pelletAngle)) * weapon.ProjectileSpeed,
+			Y: float32(math.Sin(pelletAngle)) * weapon.ProjectileSpeed,
+		}
+
+		sourceRec := Rectangle{X: 0, Y: 0, Width: 10, Height: 10}
+		position := Vector2{X: origin.X - sourceRec.Width/2, Y: origin.Y - sourceRec.Height/2}
+		bullet := Bullet{
+			id:            w.nextGameObjectId,
+			sourceRec:     sourceRec,
+			position:      position,
+			prevPosition:  position,
+			movementSpeed: weapon.ProjectileSpeed,
+			vector:        bulletVector,
+			damage:        weapon.Damage,
+			knockback:     weapon.Knockback,
+		}
+		w.gameObjects[w.nextGameObjectId] = &bullet
+		w.nextGameObjectId++
+	}
+	return true
+}
+
+func (w *World) createEnemy(archetype *Archetype, generatePosition Vector2) *Enemy {
+	enemy := Enemy{
+		id:             w.nextGameObjectId,
+		archetype:      archetype,
+		sourceRec:      Rectangle{X: 0, Y: 0, Width: archetype.Size, Height: archetype.Size},
+		position:       generatePosition,
+		prevPosition:   generatePosition,
+		movementSpeed:  0,
+		lastPlanVector: Vector2{},
+		hp:             archetype.HP,
+		nextShot:       w.clock + shooterFireInterval,
+	}
+	w.gameObjects[w.nextGameObjectId] = &enemy
+	w.nextGameObjectId++
+	return &enemy
+}
+
+// createEnemyBullet fires a slow shot from from towards target that hurts
+// the player.
+func (w *World) createEnemyBullet(from, target Vector2, speed float32) {
+	d := Vector2{X: target.X - from.X, Y: target.Y - from.Y}
+	distance := length(d)
+	if distance == 0 {
+		return
+	}
+	sourceRec := Rectangle{X: 0, Y: 0, Width: 16, Height: 16}
+	position := Vector2{X: from.X - sourceRec.Width/2, Y: from.Y - sourceRec.Height/2}
+	bullet := Bullet{
+		id:            w.nextGameObjectId,
+		sourceRec:     sourceRec,
+		position:      position,
+		prevPosition:  position,
+		movementSpeed: speed,
+		vector:        Vector2{X: d.X / distance * speed, Y: d.Y / distance * speed},
+		hostile:       true,
+	}
+	w.gameObjects[w.nextGameObjectId] = &bullet
+	w.nextGameObjectId++
+}
+
+// killEnemy removes e, leaves its body on the floor and spawns what it
+// splits into. A boss goes down slowly instead.
+func (w *World) killEnemy(e *Enemy, events *Events) {
+	if e.archetype.Boss {
+		w.defeatBoss(e, events)
+		return
+	}
+	delete(w.gameObjects, e.id)
+	w.noteKill()
+	w.createDead(e.prevPosition, e.sourceRec.Width)
+	split := e.archetype.SplitInto
+	if split == nil {
+		return
+	}
+	center := e.Shape().Centroid()
+	for i := 0; i < e.archetype.SplitCount; i++ {
+		// fan the pieces out sideways from where the parent stood
+		offset := (float32(i) - float32(e.archetype.SplitCount-1)/2) * split.Size
+		w.createEnemy(split, Vector2{
+			X: center.X - split.Size/2 + offset,
+			Y: center.Y - split.Size/2,
+		})
+	}
+}
+
+func (w *World) createDead(generatePosition Vector2, size float32) {
+	dead := Dead{
+		id:        w.nextDeadObjectId,
+		sourceRec: Rectangle{X: 0, Y: 0, Width: size, Height: size},
+		position:  generatePosition,
+	}
+	w.deadObjects[w.nextDeadObjectId] = &dead
+	w.nextDeadObjectId++
+}
+
+// maxSpawnAttempts bounds the search in generateEnemyPosition, since a stage
+// file may ask for a minimum distance the arena cannot fit.
+const maxSpawnAttempts = 1000
+
+func (w *World) generateEnemyPosition(playerCenter Vector2, enemyWidth, enemyHeight, minDistance float32) Vector2 {
+	return w.farEnoughPosition(playerCenter, enemyWidth, enemyHeight, minDistance, func() Vector2 {
+		return Vector2{
+			X: w.rng.Float32() * (w.Width - enemyWidth),
+			Y: w.rng.Float32() * (w.Height - enemyHeight),
+		}
+	})
+}
+
+// generateEdgePosition is generateEnemyPosition along the edges of the
+// arena, for waves that walk in from outside.
+func (w *World) generateEdgePosition(playerCenter Vector2, enemyWidth, enemyHeight, minDistance float32) Vector2 {
+	return w.farEnoughPosition(playerCenter, enemyWidth, enemyHeight, minDistance, func() Vector2 {
+		pos := Vector2{
+			X: w.rng.Float32() * (w.Width - enemyWidth),
+			Y: w.rng.Float32() * (w.Height - enemyHeight),
+		}
+		switch w.rng.Intn(4) {
+		case 0:
+			pos.Y = 0
+		case 1:
+			pos.X = w.Width - enemyWidth
+		case 2:
+			pos.Y = w.Height - enemyHeight
+		default:
+			pos.X = 0
+		}
+		return pos
+	})
+}
+
+// farEnoughPosition draws candidates until one is clear of obstacles and at
+// least minDistance from the player, or settles for the farthest clear one
+// after maxSpawnAttempts. If none was clear, it falls back to the corners
+// and the middles of the edges.
+func (w *World) farEnoughPosition(playerCenter Vector2, enemyWidth, enemyHeight, minDistance float32, candidate func() Vector2) Vector2 {
+	var farthest Vector2
+	farthestDistance := float32(-1)
+	for attempt := 0; attempt < maxSpawnAttempts; attempt++ {
+		pos := candidate()
+		distance, clear := w.spawnDistance(pos, playerCenter, enemyWidth, enemyHeight)
+		if !clear {
+			continue
+		}
+		if distance >= minDistance {
+			return pos
+		}
+		if distance > farthestDistance {
+			farthest = pos
+			farthestDistance = distance
+		}
+	}
+	if farthestDistance >= 0 {
+		return farthest
+	}
+
+	right, bottom := w.Width-enemyWidth, w.Height-enemyHeight
+	fallbacks := []Vector2{
+		{X: 0, Y: 0}, {X: right / 2, Y: 0}, {X: right, Y: 0},
+		{X: right, Y: bottom / 2}, {X: right, Y: bottom},
+		{X: right / 2, Y: bottom}, {X: 0, Y: bottom}, {X: 0, Y: bottom / 2},
+	}
+	var lastResort Vector2
+	lastResortDistance := float32(-1)
+	for _, pos := range fallbacks {
+		distance, clear := w.spawnDistance(pos, playerCenter, enemyWidth, enemyHeight)
+		if clear && distance > farthestDistance {
+			farthest = pos
+			farthestDistance = distance
+		}
+		if distance > lastResortDistance {
+			lastResort = pos
+			lastResortDistance = distance
+		}
+	}
+	if farthestDistance >= 0 {
+		return farthest
+	}
+	// nothing tried is clear, so the enemy at least starts far from the
+	// player
+	return lastResort
+}
+
+// spawnDistance is how far an enemy placed at pos would be from the player,
+// and whether it would be clear of obstacles there.
+func (w *World) spawnDistance(pos, playerCenter Vector2, enemyWidth, enemyHeight float32) (float32, bool) {
+	enemyCenter := Vector2{
+		X: pos.X + enemyWidth/2,
+		Y: pos.Y + enemyHeight/2,
+	}
+	distance := length(Vector2{X: enemyCenter.X - playerCenter.X, Y: enemyCenter.Y - playerCenter.Y})
+	return distance, !w.blocked(Rectangle{X: pos.X, Y: pos.Y, Width: enemyWidth, Height: enemyHeight})
+}
+
+// knockbackFriction is how much of the knockback speed is lost per second.
+const knockbackFriction = 8
+
+const (
+	// playerDash is how long a dash lasts. The player cannot steer or be
+	// slowed by anything but obstacles meanwhile.
+	playerDash = time.Duration(150) * time.Millisecond
+	// playerDashSpeed is in units per second.
+	playerDashSpeed = 2400
+	// playerDashCooldown is the time from the start of a dash until the next
+	// one can start.
+	playerDashCooldown = time.Second
+)
+
+// moveDirection is the unit vector the player is steered in by in, zero if
+// it does not steer.
+func moveDirection(in Input) Vector2 {
+	var d Vector2
+	if in.Up {
+		d.Y--
+	}
+	if in.Left {
+		d.X--
+	}
+	if in.Down {
+		d.Y++
+	}
+	if in.Right {
+		d.X++
+	}
+	if d == (Vector2{}) {
+		d = in.Move
+	}
+	return normalize(d)
+}
+
+func (w *World) playerMovement(in Input, dt time.Duration) {
+	player := w.player
+	player.prevPosition = player.position
+	seconds := float32(dt.Seconds())
+
+	if player.invulnerableLeft > 0 {
+		player.invulnerableLeft -= dt
+	}
+	if player.knockback != (Vector2{}) {
+		player.position.X += player.knockback.X * seconds
+		player.position.Y += player.knockback.Y * seconds
+		// being pushed must never carry the player out of the arena
+		body := player.Shape().Bounds()
+		player.position.X += clamp(body.X, 0, w.Width-body.Width) - body.X
+		player.position.Y += clamp(body.Y, 0, w.Height-body.Height) - body.Y
+		fade := 1 - knockbackFriction*seconds
+		if fade < 0 {
+			fade = 0
+		}
+		player.knockback.X *= fade
+		player.knockback.Y *= fade
+		if length(player.knockback) < 1 {
+			player.knockback = Vector2{}
+		}
+	}
+
+	if centre := player.Shape().Centroid(); in.Aim != centre {
+		player.aim = normalize(Vector2{X: in.Aim.X - centre.X, Y: in.Aim.Y - centre.Y})
+	}
+	player.dashCooldownLeft = max(player.dashCooldownLeft-dt, 0)
+	if in.Dash && player.dashCooldownLeft == 0 {
+		player.dashDirection = moveDirection(in)
+		if player.dashDirection == (Vector2{}) {
+			player.dashDirection = player.aim
+		}
+		if player.dashDirection != (Vector2{}) {
+			player.dashLeft = playerDash
+			player.dashCooldownLeft = playerDashCooldown
+		}
+	}
+	if player.dashLeft > 0 {
+		player.dashLeft -= dt
+		player.position.X += player.dashDirection.X * playerDashSpeed * seconds
+		player.position.Y += player.dashDirection.Y * playerDashSpeed * seconds
+		player.position = w.slide(player.prevPosition, player.position, relativeBody(player))
+		return
+	}
+
+	var movementPressedKeyCount float32 = 0
+	if in.Up {
+		movementPressedKeyCount++
+	}
+	if in.Left {
+		movementPressedKeyCount++
+	}
+	if in.Down {
+		movementPressedKeyCount++
+	}
+	if in.Right {
+		movementPressedKeyCount++
+	}
+
+	dividedMovementSpeed := player.movementSpeed * float32(dt.Seconds()) / movementPressedKeyCount
+
+	if in.Up {
+		player.position.Y = player.position.Y - dividedMovementSpeed
+		player.movement = 2
+	}
+	if in.Left {
+		player.position.X = player.position.X - dividedMovementSpeed
+		player.movement = 3
+	}
+	if in.Down {
+		player.position.Y = player.position.Y + dividedMovementSpeed
+		player.movement = 0
+	}
+	if in.Right {
+		player.position.X = player.position.X + dividedMovementSpeed
+		player.movement = 1
+	}
+	if movementPressedKeyCount == 0 && in.Move != (Vector2{}) {
+		move := in.Move
+		// a stick pushed into a corner is no faster than straight ahead
+		if length(move) > 1 {
+			move = normalize(move)
+		}
+		player.position.X += move.X * player.movementSpeed * seconds
+		player.position.Y += move.Y * player.movementSpeed * seconds
+		switch {
+		case abs(move.X) > abs(move.Y) && move.X > 0:
+			player.movement = 1
+		case abs(move.X) > abs(move.Y):
+			player.movement = 3
+		case move.Y > 0:
+			player.movement = 0
+		default:
+			player.movement = 2
+		}
+	}
+	player.position = w.slide(player.prevPosition, player.position, relativeBody(player))
+}
+
+// playerDeathCheck applies enemy contact to the player and reports whether
+// the run is over. A hit the player survives sets events.PlayerHit.
+func (w *World) playerDeathCheck(events *Events) bool {
+	playerShape := w.player.Shape()
+
+	touched := false
+	for _, obj := range w.enemiesNear(playerShape.Bounds(), nil) {
+		if !obj.IsEnemy() && !isHostileBullet(obj) {
+			continue
+		}
+		if e, ok := obj.(*Enemy); ok && !e.inPlay() {
+			continue
+		}
+		threatShape := obj.Shape()
+		if !playerShape.Overlaps(threatShape) {
+			continue
+		}
+		// enemy shots are spent on the player even during invulnerability
+		if obj.IsBullet() {
+			delete(w.gameObjects, obj.GameObjectId())
+		}
+		if touched {
+			continue
+		}
+		touched = true
+		hurt, fatal := w.hurtPlayer(threatShape)
+		if fatal {
+			return true
+		}
+		if hurt {
+			events.PlayerHit = true
+		}
+	}
+
+	playerBounds := playerShape.Bounds()
+	if playerBounds.X+playerBounds.Width < 0 || playerBounds.X > w.Width ||
+		playerBounds.Y+playerBounds.Height < 0 || playerBounds.Y > w.Height {
+		return true
+	}
+	return false
+}
+
+// hurtPlayer applies one enemy contact under the current difficulty.
+func (w *World) hurtPlayer(enemyShape Shape) (hurt bool, fatal bool) {
+	player := w.player
+	if w.difficulty.MaxHP == 0 {
+		return true, true
+	}
+	if player.invulnerableLeft > 0 {
+		return false, false
+	}
+	player.hp -= w.difficulty.ContactDamage
+	if player.hp <= 0 {
+		return true, true
+	}
+	player.invulnerableLeft = w.difficulty.Invulnerability
+
+	playerCenter := player.Shape().Centroid()
+	enemyCenter := enemyShape.Centroid()
+	away := Vector2{
+		X: playerCenter.X - enemyCenter.X,
+		Y: playerCenter.Y - enemyCenter.Y,
+	}
+	distance := length(away)
+	if distance == 0 {
+		away = Vector2{X: 0, Y: 1}
+		distance = 1
+	}
+	player.knockback = Vector2{
+		X: away.X / distance * w.difficulty.Knockback,
+		Y: away.Y / distance * w.difficulty.Knockback,
+	}
+	return true, false
+}
+
+func (w *World) hasWonStage() bool {
+	if w.mode == Endless || len(w.waves) > 0 {
+		return false
+	}
+	for _, obj := range w.gameObjects {
+		if obj.IsEnemy() {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *World) bulletCollisionCheck(events *Events) {
+	objects := w.Objects()
+	for _, bulletObj := range objects {
+		bulletKey := bulletObj.GameObjectId()
+		if bulletObj.IsBullet() {
+			bullet := bulletObj.(*Bullet)
+			bulletShape := bullet.Shape()
+
+			if bulletShape.Center.X < 0 || bulletShape.Center.Y < 0 ||
+				bulletShape.Center.X > 5000 || bulletShape.Center.Y > 5000 {
+				delete(w.gameObjects, bulletKey)
+				continue
+			}
+			bulletCurPos := bulletShape.Center
+			bulletPrevPos := bullet.center(bullet.PrevPosition())
+			// an obstacle on the way cuts the path short, nothing behind it
+			// is hit
+			wall, wallAt, normal := w.obstacleHit(bulletPrevPos, bulletCurPos, bulletShape.Radius)
+			if wall != nil {
+				bulletCurPos.X = bulletPrevPos.X + (bulletCurPos.X-bulletPrevPos.X)*wallAt
+				bulletCurPos.Y = bulletPrevPos.Y + (bulletCurPos.Y-bulletPrevPos.Y)*wallAt
+				bulletShape = CircleShape(bulletCurPos, bulletShape.Radius)
+			}
+			// enemy shots pass through enemies; playerDeathCheck handles
+			// them, if the player has not already spent them
+			if bullet.hostile {
+				if wall != nil {
+					w.hitObstacle(bullet, wall, bulletCurPos, normal)
+				}
+				continue
+			}
+
+			swept := Rectangle{
+				X:      min(bulletPrevPos.X, bulletCurPos.X) - bulletShape.Radius,
+				Y:      min(bulletPrevPos.Y, bulletCurPos.Y) - bulletShape.Radius,
+				Width:  abs(bulletCurPos.X-bulletPrevPos.X) + bulletShape.Radius*2,
+				Height: abs(bulletCurPos.Y-bulletPrevPos.Y) + bulletShape.Radius*2,
+			}
+
+			hitEnemy := false
+			for _, enemyObj := range w.enemiesNear(swept, objects) {
+				enemyKey := enemyObj.GameObjectId()
+				if bulletKey == enemyKey {
+					continue
+				}
+				// an earlier bullet may already have killed this enemy
+				if _, alive := w.gameObjects[enemyKey]; !alive {
+					continue
+				}
+				if enemy, ok := enemyObj.(*Enemy); ok && enemy.inPlay() {
+					enemyShape := enemy.Shape()
+					if bulletShape.Overlaps(enemyShape) ||
+						enemyShape.SweptOverlaps(bulletPrevPos, bulletCurPos, bulletShape.Radius) {
+						delete(w.gameObjects, bulletKey)
+						phase := enemy.Phase()
+						if enemy.hurt(bullet) {
+							w.killEnemy(enemy, events)
+						} else if enemy.Phase() != phase {
+							events.BossPhase = true
+						}
+						hitEnemy = true
+						break
+					}
+				}
+			}
+			if !hitEnemy && wall != nil {
+				w.hitObstacle(bullet, wall, bulletCurPos, normal)
+			}
+		}
+	}
+}
+
+// hitObstacle stops b where its center met o, or bounces it off the side
+// with normal if o ricochets and b has bounces left.
+func (w *World) hitObstacle(b *Bullet, o *Obstacle, center, normal Vector2) {
+	if !o.Kind.Ricochet || b.bounces >= maxRicochets || normal == (Vector2{}) {
+		delete(w.gameObjects, b.id)
+		return
+	}
+	b.bounces++
+	dot := b.vector.X*normal.X + b.vector.Y*normal.Y
+	b.vector.X -= 2 * dot * normal.X
+	b.vector.Y -= 2 * dot * normal.Y
+	// one unit off the wall, so the next step starts outside it
+	b.position = Vector2{
+		X: center.X - b.sourceRec.Width/2 + normal.X,
+		Y: center.Y - b.sourceRec.Height/2 + normal.Y,
+	}
+	b.prevPosition = b.position
+}
+
+// indexEnemies files every enemy and enemy shot into the grid at its
+// current position.
+func (w *World) indexEnemies() {
+	if w.enemyGrid == nil {
+		return
+	}
+	w.enemyGrid.clear()
+	for _, obj := range w.gameObjects {
+		if obj.IsEnemy() || isHostileBullet(obj) {
+			w.enemyGrid.insert(obj, obj.Shape().Bounds())
+		}
+	}
+}
+
+// enemiesNear returns the objects that may touch area, in id order. With the
+// grid these are the enemies and enemy shots in the cells area covers;
+// without it, objects, or every object when objects is nil. Callers still
+// check what each object is and that it is alive.
+func (w *World) enemiesNear(area Rectangle, objects []GameObject) []GameObject {
+	if w.enemyGrid != nil {
+		return w.enemyGrid.query(area)
+	}
+	if objects == nil {
+		objects = w.Objects()
+	}
+	return objects
+}
+
+func isHostileBullet(obj GameObject) bool {
+	bullet, ok := obj.(*Bullet)
+	return ok && bullet.hostile
+}
+
+func (w *World) moveGameObjects(dt time.Duration) {
+	for _, obj := range w.Objects() {
+		obj.Move(dt)
+		if e, ok := obj.(*Enemy); ok {
+			e.position = w.slide(e.prevPosition, e.position, relativeBody(e))
+		}
+	}
+}
+
+func (w *World) enemyPlan() {
+	for _, obj := range w.Objects() {
+		if obj.IsEnemy() {
+			obj.EnemyPlan(w)
+		}
+	}
+}
+
+func (w *World) CleanAllDead() {
+	for id := range w.deadObjects {
+		delete(w.deadObjects, id)
+	}
+}
+
+func (w *World) CleanAllEnemyAndBullet() {
+	for id, obj := range w.gameObjects {
+		if obj.IsEnemy() || obj.IsBullet() {
+			delete(w.gameObjects, id)
+		}
+	}
+}
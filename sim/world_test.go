@@ -0,0 +1,171 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"testing"
+)
+
+// scriptedInput walks in circles, sweeps the aim across the arena, fires,
+// reloads, dashes and switches weapons now and then, all from the step
+// number so both worlds get the same input.
+func scriptedInput(step int) Input {
+	angle := float64(step) / 10
+	in := Input{
+		Move: Vector2{X: float32(math.Cos(angle)), Y: float32(math.Sin(angle))},
+		Aim: Vector2{
+			X: 960 + 600*float32(math.Cos(angle*3)),
+			Y: 540 + 400*float32(math.Sin(angle*3)),
+		},
+		Fire:   step%3 != 0,
+		Reload: step%400 == 399,
+		Dash:   step%150 == 0,
+	}
+	if step%500 == 0 {
+		in.Weapon = step/500%3 + 1
+	}
+	return in
+}
+
+// snapshot is every object id and position in the world, in id order.
+func snapshot(w *World) string {
+	s := ""
+	for _, obj := range w.Objects() {
+		p := obj.Position()
+		s += fmt.Sprintf("%d:%v,%v ", obj.GameObjectId(), p.X, p.Y)
+	}
+	return s
+}
+
+func TestWorldIsDeterministic(t *testing.T) {
+	stages, err := LoadStages(os.DirFS("../resources/stages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const steps = 1200
+	for _, mode := range Modes {
+		// the first, a middle and the last stage
+		for _, stage := range []int{0, 7, 14} {
+			if mode == Endless && stage > 0 {
+				continue
+			}
+			t.Run(fmt.Sprintf("%s/%d", mode, stage+1), func(t *testing.T) {
+				var worlds [2]*World
+				for i := range worlds {
+					worlds[i] = NewWorld(1920, 1080, 1234, stages)
+					worlds[i].SetDifficulty(FiveLives)
+					worlds[i].SetMode(mode)
+					worlds[i].StartStage(stage)
+				}
+				for step := 0; step < steps; step++ {
+					in := scriptedInput(step)
+					a := worlds[0].Update(in, FixedStep)
+					b := worlds[1].Update(in, FixedStep)
+					if a != b {
+						t.Fatalf("step %d: events %+v and %+v", step, a, b)
+					}
+					if sa, sb := snapshot(worlds[0]), snapshot(worlds[1]); sa != sb {
+						t.Fatalf("step %d: objects\n%s\nand\n%s", step, sa, sb)
+					}
+					// go on like the game does, so the run covers more than one stage
+					for _, w := range worlds {
+						switch {
+						case a.StageCleared && !w.IsFinalStage():
+							w.StartStage(w.StageIdx() + 1)
+						case a.StageCleared || a.PlayerDied:
+							w.Reset(1234)
+							w.StartStage(stage)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestAimAtPlayerMiddleDoesNotSpendShot(t *testing.T) {
+	stages, err := LoadStages(os.DirFS("../resources/stages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWorld(1920, 1080, 1, stages)
+	w.StartStage(0)
+	ammo := w.Player().Ammo()
+	events := w.Update(Input{Fire: true, Aim: w.Player().Shape().Centroid()}, FixedStep)
+	if events.ShotFired || w.Player().Ammo() != ammo || w.player.cooldownLeft != 0 {
+		t.Fatalf("shot fired %v, ammo %d of %d, cooldown %v, want nothing spent", events.ShotFired, w.Player().Ammo(), ammo, w.player.cooldownLeft)
+	}
+}
+
+func TestSpawnFallsBackToClearEdge(t *testing.T) {
+	w := NewWorld(1000, 1000, 1, nil)
+	// everything but a strip along the right edge is blocked
+	w.obstacles = []Obstacle{{Rect: Rectangle{X: 0, Y: 0, Width: 900, Height: 1000}}}
+	player := Vector2{X: 500, Y: 500}
+	calls := 0
+	pos := w.farEnoughPosition(player, 50, 50, 1000, func() Vector2 {
+		calls++
+		return Vector2{X: 100, Y: 100}
+	})
+	if calls != maxSpawnAttempts {
+		t.Fatalf("%d candidates drawn, want %d", calls, maxSpawnAttempts)
+	}
+	if w.blocked(Rectangle{X: pos.X, Y: pos.Y, Width: 50, Height: 50}) {
+		t.Fatalf("enemy placed at %v, inside an obstacle", pos)
+	}
+}
+
+func TestGhostKeepsHeadingForWholePlan(t *testing.T) {
+	// the chase and rush steps of the ghost's random plans
+	for _, step := range []int{1, 3} {
+		w := NewWorld(1920, 1080, 1, nil)
+		w.player.teleport(Vector2{X: 1500, Y: 500})
+		e := w.createEnemy(&Ghost, Vector2{X: 300, Y: 500})
+		e.startStep(w, 1, step)
+		e.think(w)
+		heading := e.lastPlanVector
+		if heading.X <= 0 {
+			t.Fatalf("%s: heading %v, want towards the player", e.Behavior(), heading)
+		}
+		for i := 0; i < 2; i++ {
+			w.player.teleport(Vector2{X: 300, Y: 100 + 800*float32(i)})
+			w.clock += FixedStep
+			e.think(w)
+			if e.lastPlanVector != heading {
+				t.Fatalf("%s: heading turned from %v to %v after the player moved", e.Behavior(), heading, e.lastPlanVector)
+			}
+		}
+	}
+}
+
+func TestFixedSpawnKeepsAwayFromPlayer(t *testing.T) {
+	w := NewWorld(1000, 1000, 1, nil)
+	w.obstacles = []Obstacle{{Rect: Rectangle{X: 800, Y: 0, Width: 200, Height: 200}}}
+	group := EnemyGroup{Type: Ghost.Name, Count: 3, Spawn: SpawnFixed, Positions: []Vector2{
+		{X: 0, Y: 0}, // clear and far
+		{X: 1, Y: 0}, // in the obstacle
+		{X: 0, Y: 1}, // on the player
+	}}
+	player := Vector2{X: 100, Y: 850}
+	w.spawnGroup(group, player, 0)
+	var enemies []GameObject
+	for _, obj := range w.Objects() {
+		if obj.IsEnemy() {
+			enemies = append(enemies, obj)
+		}
+	}
+	if len(enemies) != 3 {
+		t.Fatalf("%d enemies, want 3", len(enemies))
+	}
+	if p := enemies[0].Position(); p != (Vector2{}) {
+		t.Errorf("clear position moved to %v", p)
+	}
+	for _, e := range enemies[1:] {
+		p := e.Position()
+		distance, clear := w.spawnDistance(p, player, Ghost.Size, Ghost.Size)
+		if !clear || distance < defaultFixedMinDistance {
+			t.Errorf("enemy at %v, %v from the player, clear %v", p, distance, clear)
+		}
+	}
+}
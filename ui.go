@@ -0,0 +1,294 @@
+package main
+
+import (
+	"brackeysGameJam/sim"
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"time"
+)
+
+type Timer struct {
+	gameInitTime time.Time
+}
+
+func (t *Timer) Init() {
+	t.gameInitTime = time.Now()
+}
+
+// printYourTime shows the run time. It is simulated time, so it stands still
+// during pauses and countdowns.
+func printYourTime(duration time.Duration, won bool, l Layout) {
+	if won {
+		l.Text(fmt.Sprintf("Your Record: %.0f s", duration.Seconds()), Center, 0, -180, Top, 50, rl.White)
+		return
+	}
+	l.Text(fmt.Sprintf("Your Time: %.0f s", duration.Seconds()), Top, 0, 0, Top, 100, rl.Black)
+}
+
+// printScore shows the endless mode score and the kills in it, large on
+// the game over screen.
+func printScore(score, kills int, final bool, l Layout) {
+	text := fmt.Sprintf("Score: %d (%d kills)", score, kills)
+	if final {
+		l.Text(text, Center, 0, -250, Top, 60, rl.White)
+		return
+	}
+	l.Text(text, Top, 0, 310, Top, 60, rl.Maroon)
+}
+
+// bossBarHeight is the height of the boss health bar along the bottom of
+// the screen.
+const bossBarHeight = 30
+
+// drawBossBar shows the boss's name, phase and health along the bottom of
+// the screen, with a mark at every phase threshold. flash lights it up
+// right after the boss changed phase.
+func drawBossBar(boss *sim.Enemy, flash bool, l Layout) {
+	archetype := boss.Archetype()
+	width := l.Width * 0.6
+	corner := l.Place(Bottom, 0, -50, Bottom, width, bossBarHeight)
+	bar := rl.Rectangle{
+		X:      corner.X,
+		Y:      corner.Y,
+		Width:  width,
+		Height: bossBarHeight,
+	}
+	rl.DrawRectangleRec(bar, rl.Fade(rl.Black, 0.6))
+	filled := bar
+	filled.Width = width * max(float32(boss.HP()), 0) / float32(archetype.HP)
+	color := rl.Color(archetype.Tint)
+	if flash {
+		color = rl.White
+	}
+	rl.DrawRectangleRec(filled, color)
+	for _, threshold := range archetype.Phases {
+		x := int32(bar.X + width*threshold)
+		rl.DrawLine(x, int32(bar.Y), x, int32(bar.Y+bar.Height), rl.Black)
+	}
+	rl.DrawRectangleLinesEx(bar, 2, rl.Black)
+	rl.DrawText(
+		fmt.Sprintf("%s - phase %d", archetype.Name, boss.Phase()),
+		int32(bar.X),
+		int32(bar.Y)-40,
+		36,
+		rl.Maroon,
+	)
+}
+
+func printTimeLeft(left time.Duration, l Layout) {
+	l.Text(fmt.Sprintf("Time Left: %.0f s", math.Ceil(left.Seconds())), Top, 0, 100, Top, 60, rl.Maroon)
+}
+
+func printLives(lives int, l Layout) {
+	l.Text(fmt.Sprintf("Lives: %d", lives), Top, 0, 170, Top, 60, rl.Maroon)
+}
+
+// printWeapon shows the weapon in hand and its magazine, or that it is
+// reloading.
+func printWeapon(player *sim.Player, l Layout) {
+	weapon := player.Weapon()
+	text := fmt.Sprintf("%s %d/%d", weapon.Name, player.Ammo(), weapon.Magazine)
+	if left := player.ReloadLeft(); left > 0 {
+		text = fmt.Sprintf("%s reloading %.1f s", weapon.Name, left.Seconds())
+	}
+	l.Text(text, Top, 0, 240, Top, 50, rl.Maroon)
+}
+
+// printRecords lists personal bests below the button of the end screens.
+func printRecords(lines []string, l Layout) {
+	for i, line := range lines {
+		l.Text(line, Center, 0, float32(60+i*45), Top, 36, rl.White)
+	}
+}
+
+// victoryRecords are the lines printRecords shows after a won run.
+func victoryRecords(g *Game, newBest bool) []string {
+	board := g.board()
+	if board == nil {
+		return nil
+	}
+	var lines []string
+	if newBest {
+		lines = append(lines, "New personal best!")
+	}
+	if best := board.BestRun; best != nil {
+		lines = append(lines, fmt.Sprintf("Best run: %.1f s (%s, seed %d)", best.Seconds, best.Date.Format("2006-01-02"), best.Seed))
+	}
+	return lines
+}
+
+// gameOverRecords are the lines printRecords shows after a lost run: the
+// endless record, or the records of the stage the player died in.
+func gameOverRecords(g *Game, newBest bool) []string {
+	board := g.board()
+	if board == nil {
+		return nil
+	}
+	var lines []string
+	if g.world.Mode() == sim.Endless {
+		if newBest {
+			lines = append(lines, "New personal best!")
+		}
+		if best := board.BestEndless; best != nil {
+			lines = append(lines, fmt.Sprintf("Best score: %d (%s, seed %d)", best.Score, best.Date.Format("2006-01-02"), best.Seed))
+		}
+		return lines
+	}
+	stage := board.Stage(g.world.Stage().Name)
+	if best := stage.Best; best != nil {
+		lines = append(lines, fmt.Sprintf("Stage %s best: %.1f s", g.world.Stage().Name, best.Seconds))
+	}
+	lines = append(lines, fmt.Sprintf("Deaths in stage %s: %d", g.world.Stage().Name, stage.Deaths))
+	if best := board.BestRun; best != nil {
+		lines = append(lines, fmt.Sprintf("Best run: %.1f s", best.Seconds))
+	}
+	return lines
+}
+
+// printSeed shows the run seed so players can share it and bug reports can
+// replay the run.
+func printSeed(seed int64, l Layout) {
+	l.Text(fmt.Sprintf("seed: %d", seed), Center, 0, 350, Top, 30, rl.White)
+}
+
+type menuItem struct {
+	label  string
+	action func()
+}
+
+// Menu is a vertical list of items picked with the arrow keys and enter, or
+// with the mouse.
+type Menu struct {
+	items    []menuItem
+	selected int
+	position rl.Vector2
+	fontSize int32
+}
+
+func (m *Menu) itemRect(i int) rl.Rectangle {
+	return rl.Rectangle{
+		X:      m.position.X,
+		Y:      m.position.Y + float32(i)*float32(m.fontSize+20),
+		Width:  float32(rl.MeasureText(m.items[i].label, m.fontSize)),
+		Height: float32(m.fontSize),
+	}
+}
+
+// Update moves the selection and runs the action of a picked item.
+// mousePosition is the mouse on the canvas.
+func (m *Menu) Update(mousePosition rl.Vector2) {
+	if rl.IsKeyPressed(rl.KeyDown) || rl.IsKeyPressed(rl.KeyS) {
+		m.selected = (m.selected + 1) % len(m.items)
+	}
+	if rl.IsKeyPressed(rl.KeyUp) || rl.IsKeyPressed(rl.KeyW) {
+		m.selected = (m.selected + len(m.items) - 1) % len(m.items)
+	}
+	for i := range m.items {
+		if rl.CheckCollisionPointRec(mousePosition, m.itemRect(i)) {
+			if rl.GetMouseDelta() != (rl.Vector2{}) {
+				m.selected = i
+			}
+			if rl.IsMouseButtonReleased(rl.MouseLeftButton) {
+				m.selected = i
+				m.items[i].action()
+				return
+			}
+		}
+	}
+	if rl.IsKeyPressed(rl.KeyEnter) || rl.IsKeyPressed(rl.KeySpace) {
+		m.items[m.selected].action()
+	}
+}
+
+func (m *Menu) Draw() {
+	for i, item := range m.items {
+		rect := m.itemRect(i)
+		color := rl.Black
+		if i == m.selected {
+			color = rl.Color{
+				R: 250,
+				G: 200,
+				B: 0,
+				A: 255,
+			}
+		}
+		rl.DrawText(item.label, int32(rect.X), int32(rect.Y), m.fontSize, color)
+	}
+}
+
+type Button struct {
+	id               int
+	texture          rl.Texture2D
+	sourceRec        rl.Rectangle
+	position         rl.Vector2
+	color            rl.Color
+	status           int
+	buttonClickSound rl.Sound
+}
+
+// newButton makes the "go" button in the middle of the screen.
+func newButton(texture rl.Texture2D, l Layout, color rl.Color) Button {
+	return Button{
+		id:        -1,
+		texture:   texture,
+		sourceRec: rl.Rectangle{X: 0, Y: 0, Width: 220, Height: 100},
+		position:  l.Place(Center, 0, -60, Center, 220, 100),
+		color:     color,
+		status:    0,
+	}
+}
+
+func (b *Button) Draw() {
+	if b.status == 0 {
+		b.sourceRec.Y = 0
+	} else if b.status == 1 {
+		b.sourceRec.Y = 110
+	} else if b.status == 2 {
+		b.sourceRec.Y = 220
+	}
+	rl.DrawTextureRec(
+		b.texture,
+		b.sourceRec,
+		b.position,
+		b.color,
+	)
+	rl.DrawText(
+		fmt.Sprintf(
+			"go",
+		),
+		int32(b.position.X+80),
+		int32(b.position.Y+25),
+		40,
+		rl.Color{
+			R: 250,
+			G: 200,
+			B: 0,
+			A: 200,
+		},
+	)
+}
+
+func (b *Button) CheckInput(
+	mousePosition rl.Vector2,
+) bool {
+	if mousePosition.X >= b.position.X &&
+		mousePosition.X <= b.sourceRec.Width+b.position.X &&
+		mousePosition.Y >= b.position.Y &&
+		mousePosition.Y <= b.sourceRec.Height+b.position.Y &&
+		rl.IsMouseButtonDown(rl.MouseLeftButton) {
+		b.status = 1
+	} else {
+		b.status = 0
+	}
+
+	if mousePosition.X >= b.position.X &&
+		mousePosition.X <= b.sourceRec.Width+b.position.X &&
+		mousePosition.Y >= b.position.Y &&
+		mousePosition.Y <= b.sourceRec.Height+b.position.Y &&
+		rl.IsMouseButtonReleased(rl.MouseLeftButton) {
+		b.status = 2
+		return true
+	}
+	return false
+}